@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	capabilityIAM        = "CAPABILITY_IAM"
+	capabilityNamedIAM   = "CAPABILITY_NAMED_IAM"
+	capabilityAutoExpand = "CAPABILITY_AUTO_EXPAND"
+)
+
+var knownCapabilities = []string{capabilityIAM, capabilityNamedIAM, capabilityAutoExpand}
+
+// iamNameProperties maps the IAM resource types that can be given a custom
+// name to the property naming them.
+var iamNameProperties = map[string]string{
+	"AWS::IAM::Role":            "RoleName",
+	"AWS::IAM::User":            "UserName",
+	"AWS::IAM::Group":           "GroupName",
+	"AWS::IAM::ManagedPolicy":   "ManagedPolicyName",
+	"AWS::IAM::InstanceProfile": "InstanceProfileName",
+}
+
+// capability is a capability the new stack is created with, and why.
+type capability struct {
+	Name   string
+	Reason string
+}
+
+// requiredCapabilities works out the capabilities creating a stack from t
+// needs, with the first reason found for each:
+//
+//   - CAPABILITY_IAM for IAM resources, serverless resources and nested stacks
+//   - CAPABILITY_NAMED_IAM for IAM resources given a custom name
+//   - CAPABILITY_AUTO_EXPAND for transforms, macros and nested stacks
+func requiredCapabilities(t *cfnTemplate) []capability {
+	reasons := map[string]string{}
+	add := func(name string, reason string) {
+		if _, ok := reasons[name]; !ok {
+			reasons[name] = reason
+		}
+	}
+
+	for _, name := range t.transforms() {
+		add(capabilityAutoExpand, fmt.Sprintf("the template uses the %s transform", name))
+		if strings.HasPrefix(name, "AWS::Serverless") {
+			add(capabilityIAM, fmt.Sprintf("the %s transform creates IAM roles", name))
+		}
+	}
+
+	keys := []string{}
+	for k := range t.Resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		r := t.Resources[k]
+		switch {
+		case strings.HasPrefix(r.Type, "AWS::IAM::"):
+			add(capabilityIAM, fmt.Sprintf("resource '%s' is an %s", k, r.Type))
+			if property, ok := iamNameProperties[r.Type]; ok {
+				if _, named := r.Properties[property]; named {
+					add(capabilityNamedIAM, fmt.Sprintf("resource '%s' sets %s", k, property))
+				}
+			}
+		case strings.HasPrefix(r.Type, "AWS::Serverless::"):
+			add(capabilityIAM, fmt.Sprintf("resource '%s' is an %s", k, r.Type))
+		case r.Type == "AWS::CloudFormation::Stack":
+			add(capabilityIAM, fmt.Sprintf("nested stack '%s' may create IAM resources", k))
+			add(capabilityAutoExpand, fmt.Sprintf("nested stack '%s' may use macros", k))
+		}
+
+		if usesMacro(r.Properties) {
+			add(capabilityAutoExpand, fmt.Sprintf("resource '%s' uses Fn::Transform", k))
+		}
+	}
+
+	capabilities := []capability{}
+	for _, name := range knownCapabilities {
+		if reason, ok := reasons[name]; ok {
+			capabilities = append(capabilities, capability{Name: name, Reason: reason})
+		}
+	}
+
+	return capabilities
+}
+
+// usesMacro reports whether v contains an Fn::Transform anywhere.
+func usesMacro(v interface{}) bool {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			if k == "Fn::Transform" || usesMacro(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			if usesMacro(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// capabilitiesFromCli splits the --capabilities flags, which may each hold a
+// comma separated list.
+func capabilitiesFromCli(values []string) []capability {
+	capabilities := []capability{}
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				capabilities = append(capabilities, capability{Name: name, Reason: "set with --capabilities"})
+			}
+		}
+	}
+	return capabilities
+}
+
+func capabilityNames(capabilities []capability) []string {
+	names := []string{}
+	for _, c := range capabilities {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func capabilityReasons(capabilities []capability) map[string]string {
+	reasons := map[string]string{}
+	for _, c := range capabilities {
+		reasons[c.Name] = c.Reason
+	}
+	return reasons
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var requiredCapabilitiesTcs = []struct {
+	template string
+	expected []capability
+}{
+	{`{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}}}`, []capability{}},
+	{
+		`{"Resources": {"Role": {"Type": "AWS::IAM::Role", "Properties": {}}}}`,
+		[]capability{{capabilityIAM, "resource 'Role' is an AWS::IAM::Role"}},
+	},
+	{
+		`{"Resources": {"Role": {"Type": "AWS::IAM::Role", "Properties": {"RoleName": "app"}}, "User": {"Type": "AWS::IAM::User", "Properties": {"UserName": "app"}}}}`,
+		[]capability{
+			{capabilityIAM, "resource 'Role' is an AWS::IAM::Role"},
+			{capabilityNamedIAM, "resource 'Role' sets RoleName"},
+		},
+	},
+	{
+		`{"Transform": "AWS::Serverless-2016-10-31", "Resources": {"Fn": {"Type": "AWS::Serverless::Function"}}}`,
+		[]capability{
+			{capabilityIAM, "the AWS::Serverless-2016-10-31 transform creates IAM roles"},
+			{capabilityAutoExpand, "the template uses the AWS::Serverless-2016-10-31 transform"},
+		},
+	},
+	{
+		`{"Transform": ["MyMacro"], "Resources": {}}`,
+		[]capability{{capabilityAutoExpand, "the template uses the MyMacro transform"}},
+	},
+	{
+		`{"Resources": {"Child": {"Type": "AWS::CloudFormation::Stack", "Properties": {"TemplateURL": "https://example.com/child.json"}}}}`,
+		[]capability{
+			{capabilityIAM, "nested stack 'Child' may create IAM resources"},
+			{capabilityAutoExpand, "nested stack 'Child' may use macros"},
+		},
+	},
+	{
+		`{"Resources": {"Topic": {"Type": "AWS::SNS::Topic", "Properties": {"Tags": [{"Fn::Transform": {"Name": "AddTags"}}]}}}}`,
+		[]capability{{capabilityAutoExpand, "resource 'Topic' uses Fn::Transform"}},
+	},
+}
+
+func TestRequiredCapabilities(t *testing.T) {
+	for _, tc := range requiredCapabilitiesTcs {
+		tmpl, err := parseTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("Expected no error got '%v'", err)
+		}
+
+		if c := requiredCapabilities(tmpl); !reflect.DeepEqual(c, tc.expected) {
+			t.Fatalf("Expected '%v' got '%v'", tc.expected, c)
+		}
+	}
+}
+
+func TestCapabilitiesFromCli(t *testing.T) {
+	c := capabilitiesFromCli([]string{"CAPABILITY_IAM, CAPABILITY_AUTO_EXPAND", "CAPABILITY_NAMED_IAM"})
+	expected := []string{capabilityIAM, capabilityAutoExpand, capabilityNamedIAM}
+	if !reflect.DeepEqual(capabilityNames(c), expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, capabilityNames(c))
+	}
+}
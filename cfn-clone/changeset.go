@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	changeSetTypeCreate = "CREATE"
+	changeSetTypeUpdate = "UPDATE"
+)
+
+// allowAllStackPolicy replaces a dropped stack policy on update, as a stack
+// policy can not be deleted once set.
+const allowAllStackPolicy = `{"Statement":[{"Effect":"Allow","Action":"Update:*","Principal":"*","Resource":"*"}]}`
+
+var (
+	// errChangeSetRejected is returned when the change set was not approved,
+	// and has been deleted.
+	errChangeSetRejected = errors.New("The change set was rejected and deleted")
+	// errNoChanges is returned when an update change set would not change
+	// anything, and has been deleted.
+	errNoChanges = errors.New("The change set has no changes")
+)
+
+// changeSetName names the change set of a clone or sync made at now.
+func changeSetName(now time.Time) string {
+	return "cfn-clone-" + now.UTC().Format("20060102T150405Z")
+}
+
+func changeSetStatusInProgress(status string) bool {
+	return status == "CREATE_PENDING" || status == "CREATE_IN_PROGRESS"
+}
+
+// waitForChangeSet polls a change set until CloudFormation has worked out its
+// changes, returning an error with the reason if that failed.
+func waitForChangeSet(client CloudFormationClient, stack string, name string) (*ChangeSet, error) {
+	for {
+		cs, err := client.DescribeChangeSet(stack, name)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to describe change set '%s'. %s", name, err.Error())
+		}
+
+		if !changeSetStatusInProgress(cs.Status) {
+			if cs.Status == "FAILED" && changeSetHasNoChanges(cs.StatusReason) {
+				return nil, errNoChanges
+			}
+			if cs.Status != "CREATE_COMPLETE" {
+				return nil, fmt.Errorf("Change set '%s' ended in %s. %s", name, cs.Status, cs.StatusReason)
+			}
+			return cs, nil
+		}
+
+		time.Sleep(stackPollInterval)
+	}
+}
+
+// changeSetHasNoChanges reports whether a change set failed only because
+// the stack is already up to date.
+func changeSetHasNoChanges(reason string) bool {
+	return strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed")
+}
+
+// changeSetTable renders the resource changes of a change set.
+func changeSetTable(cs *ChangeSet) string {
+	var b bytes.Buffer
+	w := new(tabwriter.Writer)
+	w.Init(&b, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(&b, "Change set '%s' (%d resources):\n", cs.ChangeSetName, len(cs.Changes))
+	fmt.Fprintln(w, "  Action\tLogical ID\tType")
+	for _, c := range cs.Changes {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", c.Action, c.LogicalResourceId, c.ResourceType)
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// approveChangeSet reports whether the change set should be executed, which
+// with no prompter requires --approve.
+func approveChangeSet(options *options, in prompter) (bool, error) {
+	if options.Approve {
+		return true, nil
+	}
+
+	if in == nil {
+		return false, errors.New("Set --approve to execute the change set when stdin is not a terminal.")
+	}
+
+	answer, err := in.prompt("Execute the change set? (y/N)")
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// discardChangeSet deletes a change set, and for a CREATE change set the
+// stack CloudFormation created in REVIEW_IN_PROGRESS to hold it.
+func discardChangeSet(client CloudFormationClient, stack string, name string, changeSetType string, out io.Writer) {
+	if err := client.DeleteChangeSet(stack, name); err != nil {
+		fmt.Fprintf(out, "Warning: unable to delete change set '%s'. %s\n", name, err.Error())
+	}
+	if changeSetType != changeSetTypeCreate {
+		return
+	}
+	if err := client.DeleteStack(stack); err != nil {
+		fmt.Fprintf(out, "Warning: unable to delete stack '%s' awaiting the change set. %s\n", stack, err.Error())
+	}
+}
+
+// applyChangeSet creates or updates a stack through a change set of
+// changeSetType, executing it once its changes are approved. Settings change
+// sets can not carry are applied to the stack once it is executed.
+func applyChangeSet(client CloudFormationClient, input *StackInput, changeSetType string, options *options, in prompter, now time.Time, out io.Writer) (string, error) {
+	name := changeSetName(now)
+	if _, err := client.CreateChangeSet(&ChangeSetInput{
+		StackInput:    *input,
+		ChangeSetName: name,
+		ChangeSetType: changeSetType,
+	}); err != nil {
+		return "", fmt.Errorf("Unable to create change set. %s", err.Error())
+	}
+
+	cs, err := waitForChangeSet(client, input.StackName, name)
+	if err != nil {
+		discardChangeSet(client, input.StackName, name, changeSetType, out)
+		return "", err
+	}
+
+	fmt.Fprintln(out, changeSetTable(cs))
+
+	approved, err := approveChangeSet(options, in)
+	if err == nil && !approved {
+		err = errChangeSetRejected
+	}
+	if err != nil {
+		discardChangeSet(client, input.StackName, name, changeSetType, out)
+		return "", err
+	}
+
+	if err = client.ExecuteChangeSet(input.StackName, name); err != nil {
+		return "", fmt.Errorf("Unable to execute change set '%s'. %s", name, err.Error())
+	}
+
+	stack := cs.StackId
+	if stack == "" {
+		stack = input.StackName
+	}
+
+	if input.EnableTerminationProtection || changeSetType == changeSetTypeUpdate {
+		if err = client.UpdateTerminationProtection(stack, input.EnableTerminationProtection); err != nil {
+			return "", fmt.Errorf("Unable to update termination protection. %s", err.Error())
+		}
+	}
+
+	policy := input.StackPolicyBody
+	if policy == "" && options.NoStackPolicy && changeSetType == changeSetTypeUpdate {
+		policy = allowAllStackPolicy
+	}
+	if policy != "" {
+		if err = client.SetStackPolicy(stack, policy); err != nil {
+			return "", fmt.Errorf("Unable to set the stack policy. %s", err.Error())
+		}
+	}
+
+	if input.TimeoutInMinutes > 0 && changeSetType == changeSetTypeCreate {
+		fmt.Fprintln(out, "Warning: change sets can not set a creation timeout, so TimeoutInMinutes is not applied.")
+	}
+
+	return stack, nil
+}
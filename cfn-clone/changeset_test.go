@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestChangeSetName(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	if name := changeSetName(now); name != "cfn-clone-20240506T070809Z" {
+		t.Fatalf("Expected 'cfn-clone-20240506T070809Z' got '%v'", name)
+	}
+}
+
+func TestChangeSetTable(t *testing.T) {
+	cs := &ChangeSet{
+		ChangeSetName: "cfn-clone-1",
+		Changes: []ResourceChange{
+			{Action: "Add", LogicalResourceId: "Bucket", ResourceType: "AWS::S3::Bucket"},
+			{Action: "Add", LogicalResourceId: "QueuePolicy", ResourceType: "AWS::SQS::QueuePolicy"},
+		},
+	}
+
+	expected := regexp.MustCompile(`Change set 'cfn-clone-1' \(2 resources\):\n  Action\s+Logical ID\s+Type\n  Add\s+Bucket\s+AWS::S3::Bucket\n  Add\s+QueuePolicy\s+AWS::SQS::QueuePolicy\n`)
+	if out := changeSetTable(cs); !expected.MatchString(out) {
+		t.Fatalf("Expected '%v' got '%v'", expected, out)
+	}
+}
+
+var approveChangeSetTcs = []struct {
+	approve       bool
+	in            prompter
+	expected      bool
+	resultIsError bool
+}{
+	{true, nil, true, false},
+	{false, nil, false, true},
+	{false, &scriptedPrompter{answers: []string{"y"}}, true, false},
+	{false, &scriptedPrompter{answers: []string{" YES "}}, true, false},
+	{false, &scriptedPrompter{answers: []string{""}}, false, false},
+	{false, &scriptedPrompter{answers: []string{"no"}}, false, false},
+	{false, &scriptedPrompter{}, false, true},
+}
+
+func TestApproveChangeSet(t *testing.T) {
+	for _, tc := range approveChangeSetTcs {
+		approved, err := approveChangeSet(&options{Approve: tc.approve}, tc.in)
+		if approved != tc.expected || (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v' with '%v'", tc.expected, approved, err)
+		}
+	}
+}
+
+func TestApproveChangeSetWithoutTerminal(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Unable to open %s: %v", os.DevNull, err)
+	}
+	defer null.Close()
+
+	var out bytes.Buffer
+	approved, err := approveChangeSet(&options{}, stdinPrompter(null, &out))
+	expected := "Set --approve to execute the change set when stdin is not a terminal."
+	if approved || err == nil || err.Error() != expected {
+		t.Fatalf("Expected '%v' got '%v' with '%v'", expected, approved, err)
+	}
+}
+
+func TestApplyChangeSet(t *testing.T) {
+	c := newFakeClient()
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	c.changeSets[changeSetName(now)] = &ChangeSet{
+		ChangeSetName: changeSetName(now),
+		StackId:       "arn:aws:cloudformation:us-east-1:123456789012:stack/clone/1",
+		Status:        "CREATE_COMPLETE",
+		Changes:       []ResourceChange{{Action: "Add", LogicalResourceId: "Bucket", ResourceType: "AWS::S3::Bucket"}},
+	}
+	input := &StackInput{
+		StackName:     "clone",
+		StackSettings: StackSettings{StackPolicyBody: `{"Statement": []}`, EnableTerminationProtection: true},
+	}
+
+	var out bytes.Buffer
+	in := &scriptedPrompter{answers: []string{"y"}}
+	stack, err := applyChangeSet(c, input, changeSetTypeCreate, &options{}, in, now, &out)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if stack != "arn:aws:cloudformation:us-east-1:123456789012:stack/clone/1" || c.createdChangeSet[0].ChangeSetType != "CREATE" {
+		t.Fatalf("Expected a CREATE change set for the clone got '%v' '%v'", stack, c.createdChangeSet[0])
+	}
+
+	if len(c.executed) != 1 || len(c.deletedChangeSet) != 0 {
+		t.Fatalf("Expected the change set to be executed got '%v' '%v'", c.executed, c.deletedChangeSet)
+	}
+
+	if !c.protected[stack] || c.setPolicies[stack] != `{"Statement": []}` {
+		t.Fatalf("Expected termination protection and the stack policy to be set got '%v' '%v'", c.protected, c.setPolicies)
+	}
+
+	if !regexp.MustCompile(`Add\s+Bucket\s+AWS::S3::Bucket`).MatchString(out.String()) {
+		t.Fatalf("Expected the change set table got '%v'", out.String())
+	}
+}
+
+func TestApplyChangeSetRejected(t *testing.T) {
+	c := newFakeClient()
+
+	var out bytes.Buffer
+	in := &scriptedPrompter{answers: []string{"n"}}
+	if _, err := applyChangeSet(c, &StackInput{StackName: "clone"}, changeSetTypeCreate, &options{}, in, time.Now(), &out); err != errChangeSetRejected {
+		t.Fatalf("Expected '%v' got '%v'", errChangeSetRejected, err)
+	}
+
+	if len(c.executed) != 0 || len(c.deletedChangeSet) != 1 || len(c.deleted) != 1 || c.deleted[0] != "clone" {
+		t.Fatalf("Expected the change set and its stack to be deleted got '%v' '%v' '%v'", c.executed, c.deletedChangeSet, c.deleted)
+	}
+}
+
+func TestApplyChangeSetFailed(t *testing.T) {
+	c := newFakeClient()
+	now := time.Now()
+	c.changeSets[changeSetName(now)] = &ChangeSet{Status: "FAILED", StatusReason: "Template error"}
+
+	var out bytes.Buffer
+	_, err := applyChangeSet(c, &StackInput{StackName: "clone"}, changeSetTypeCreate, &options{Approve: true}, nil, now, &out)
+	if err == nil || !regexp.MustCompile(`ended in FAILED. Template error`).MatchString(err.Error()) {
+		t.Fatalf("Expected the failure reason got '%v'", err)
+	}
+
+	if len(c.executed) != 0 || len(c.deletedChangeSet) != 1 {
+		t.Fatalf("Expected the failed change set to be deleted got '%v' '%v'", c.executed, c.deletedChangeSet)
+	}
+}
@@ -9,21 +9,75 @@ import (
 )
 
 type options struct {
-	Attributes []string `short:"a" long:"attributes" description:"'=' separated attribute and value"`
-	NewName    string   `short:"n" long:"new-name" description:"Name for new stack" required:"true"`
-	SourceName string   `short:"s" long:"source-name" description:"Name of source stack to clone" required:"true"`
-	Template   string   `short:"t" long:"template" description:"Path to a new template file"`
-	Version    func()   `short:"v" long:"version" description:"Display the version of cfn-clone"`
+	Attributes                []string `short:"a" long:"attributes" description:"'=' separated attribute and value"`
+	AllowUnknown              bool     `long:"allow-unknown-params" description:"Send -a parameters the template does not declare"`
+	Approve                   bool     `long:"approve" description:"Execute the change set without asking for approval"`
+	Backend                   string   `long:"backend" description:"How to talk to CloudFormation, 'cli' or 'sdk'" default:"cli"`
+	Capabilities              []string `long:"capabilities" description:"Capabilities to create the stack with instead of detecting them from the template, comma separated"`
+	ChangeSet                 bool     `long:"change-set" description:"Create the new stack through a change set, showing its changes for approval"`
+	DryRun                    bool     `long:"dry-run" description:"Print the clone plan without creating the stack"`
+	EndpointURL               string   `long:"endpoint-url" description:"Override the CloudFormation endpoint URL"`
+	ExportPrefix              string   `long:"export-prefix" description:"Prefix added to the export names of the new stack"`
+	ExportSuffix              string   `long:"export-suffix" description:"Suffix added to the export names of the new stack, defaults to '-' and the new stack name"`
+	KeepStagedTemplate        bool     `long:"keep-staged-template" description:"Leave the staged template in S3 after the stack is created"`
+	NewName                   string   `short:"n" long:"new-name" description:"Name for new stack" required:"true"`
+	NoArnRewrite              bool     `long:"no-arn-rewrite" description:"Keep ARNs pointing at the source region and account"`
+	NoExportRename            bool     `long:"no-export-rename" description:"Keep the template's export names as they are"`
+	NoNotifications           bool     `long:"no-notifications" description:"Leave the source stack's notification ARNs off the new stack"`
+	NoRollbackConfiguration   bool     `long:"no-rollback-configuration" description:"Leave the source stack's rollback triggers off the new stack"`
+	NoStackPolicy             bool     `long:"no-stack-policy" description:"Leave the source stack's stack policy off the new stack"`
+	NoStackRole               bool     `long:"no-stack-role" description:"Leave the source stack's service role off the new stack"`
+	NoTerminationProtection   bool     `long:"no-termination-protection" description:"Create the new stack without termination protection"`
+	NoTimeout                 bool     `long:"no-timeout" description:"Leave the source stack's creation timeout off the new stack"`
+	NotificationARNs          []string `long:"notification-arn" description:"SNS topic to notify of the new stack's events instead of the source stack's"`
+	ParamsFiles               []string `long:"parameters-file" description:"JSON or YAML file of parameter overrides, applied before -a"`
+	PhysicalNames             string   `long:"physical-names" description:"What to do with hard-coded physical resource names that collide with the source stack's, 'keep', 'suffix' with '-' and the new stack name, or 'remove'" default:"keep"`
+	PinSSM                    bool     `long:"pin-ssm" description:"Freeze SSM parameter types at the values the source stack resolved"`
+	RemoveTags                []string `long:"remove-tag" description:"Tag key of the source stack to leave off the new stack"`
+	RedactPattern             string   `long:"redact-pattern" description:"Mask values of parameters whose key matches this regular expression" default:"(?i)(password|passwd|secret|token|credential|private_?key)"`
+	RewriteMap                string   `long:"rewrite-map" description:"JSON or YAML map of source values, such as AMI IDs, to their target values"`
+	RoleSessionName           string   `long:"role-session-name" description:"Session name used when assuming --source-role-arn or --target-role-arn" default:"cfn-clone"`
+	RollbackConfigurationFile string   `long:"rollback-configuration-file" description:"JSON or YAML rollback configuration for the new stack instead of the source stack's"`
+	SourceName                string   `short:"s" long:"source-name" description:"Name of source stack to clone" required:"true"`
+	SourceExternalID          string   `long:"source-external-id" description:"External ID for assuming --source-role-arn"`
+	SourceProfile             string   `long:"source-profile" description:"aws profile to read the source stack with"`
+	SourceRegion              string   `long:"source-region" description:"Region of the source stack"`
+	SourceRoleARN             string   `long:"source-role-arn" description:"IAM role to assume to read the source stack"`
+	StackPolicyFile           string   `long:"stack-policy-file" description:"JSON stack policy for the new stack instead of the source stack's"`
+	StackRoleARN              string   `long:"stack-role-arn" description:"Service role CloudFormation uses for the new stack instead of the source stack's"`
+	Sync                      bool     `long:"sync" description:"Update the existing clone -n from the source stack through a change set, keeping the parameters it overrode"`
+	Tags                      []string `long:"tag" description:"'=' separated tag key and value for the new stack"`
+	TargetAccountID           string   `long:"target-account-id" description:"Account the new stack is created in, defaults to the account of --target-role-arn"`
+	TargetExternalID          string   `long:"target-external-id" description:"External ID for assuming --target-role-arn"`
+	TargetProfile             string   `long:"target-profile" description:"aws profile to create the new stack with"`
+	TargetRegion              string   `long:"target-region" description:"Region to create the new stack in"`
+	TargetRoleARN             string   `long:"target-role-arn" description:"IAM role to assume to create the new stack"`
+	Template                  string   `short:"t" long:"template" description:"Path to a new template file"`
+	TemplateBucket            string   `long:"template-bucket" description:"S3 bucket to stage templates too large to pass inline in"`
+	TemplateKMSKeyID          string   `long:"template-kms-key-id" description:"KMS key to encrypt staged templates with"`
+	TemplatePrefix            string   `long:"template-prefix" description:"Prefix for the keys of staged templates"`
+	TerminationProtection     bool     `long:"termination-protection" description:"Create the new stack with termination protection"`
+	TimeoutInMinutes          int64    `long:"timeout-in-minutes" description:"Minutes before the new stack's creation times out, instead of the source stack's"`
+	Version                   func()   `short:"v" long:"version" description:"Display the version of cfn-clone"`
+	Wait                      bool     `short:"w" long:"wait" description:"Wait for the new stack to complete, streaming its events"`
 }
 
-func paramsFromCli(attribs []string) map[string]string {
-	parameters := map[string]string{}
-	for _, a := range attribs {
+func keyValuesFromCli(pairs []string) map[string]string {
+	values := map[string]string{}
+	for _, a := range pairs {
 		p := strings.SplitN(a, "=", 2)
-		parameters[p[0]] = p[1]
+		values[p[0]] = p[1]
 	}
 
-	return parameters
+	return values
+}
+
+func paramsFromCli(attribs []string) map[string]string {
+	return keyValuesFromCli(attribs)
+}
+
+func tagsFromCli(tags []string) map[string]string {
+	return keyValuesFromCli(tags)
 }
 
 func parseCliArgs() *options {
@@ -53,7 +107,19 @@ func parseCliArgs() *options {
 		os.Exit(1)
 	}
 
-	if err = validateCliExists("aws"); err != nil {
+	if err = validateBackend(opts.Backend); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if opts.Backend == cliBackend {
+		if err = validateCliExists("aws"); err != nil {
+			fmt.Printf("%s", err)
+			os.Exit(1)
+		}
+	}
+
+	if err = validateCapabilities(opts.Capabilities); err != nil {
 		fmt.Printf("%s", err)
 		os.Exit(1)
 	}
@@ -63,12 +129,42 @@ func parseCliArgs() *options {
 		os.Exit(1)
 	}
 
+	if err = validateCliTags(opts.Tags); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
 	if err = validateTemplateExists(opts.Template); err != nil {
 		fmt.Printf("%s", err)
 		os.Exit(1)
 	}
 
-	if err = validateSourceStackExists(opts.SourceName); err != nil {
+	if err = validateParametersFilesExist(opts.ParamsFiles); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if err = validateRewriteMapExists(opts.RewriteMap); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if err = validateChangeSetFlags(opts); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if err = validateExportFlags(opts); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if err = validatePhysicalNames(opts.PhysicalNames); err != nil {
+		fmt.Printf("%s", err)
+		os.Exit(1)
+	}
+
+	if err = validateSettingsFlags(opts); err != nil {
 		fmt.Printf("%s", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	cliBackend = "cli"
+	sdkBackend = "sdk"
+)
+
+// CloudFormationClient is the set of CloudFormation operations cfn-clone
+// relies on. The default implementation shells out to the aws cli, but any
+// implementation can be plugged into clone.
+type CloudFormationClient interface {
+	DescribeStack(name string) (*Stack, error)
+	GetTemplate(name string) (string, error)
+	GetStackPolicy(name string) (string, error)
+	SetStackPolicy(name string, body string) error
+	UpdateTerminationProtection(name string, enable bool) error
+	CreateStack(input *StackInput) (string, error)
+	UpdateStack(input *StackInput) (string, error)
+	DeleteStack(name string) error
+	// DescribeStackEvents returns the events of a stack newest first,
+	// stopping at the first page that reaches an event in seen.
+	DescribeStackEvents(name string, seen map[string]bool) ([]StackEvent, error)
+	CreateChangeSet(input *ChangeSetInput) (string, error)
+	DescribeChangeSet(stack string, changeSet string) (*ChangeSet, error)
+	ExecuteChangeSet(stack string, changeSet string) error
+	DeleteChangeSet(stack string, changeSet string) error
+}
+
+// secretStore looks up values in SSM Parameter Store and Secrets Manager
+// with the same credentials and region as the CloudFormation backend.
+type secretStore interface {
+	GetParameter(name string) (string, error)
+	GetSecretValue(id string) (string, error)
+}
+
+// templateStager uploads templates too large to pass inline to S3, with the
+// same credentials and region as the CloudFormation backend.
+type templateStager interface {
+	PutTemplate(bucket string, key string, body string, kmsKeyID string) (string, error)
+	DeleteTemplate(bucket string, key string) error
+}
+
+// Stack is the subset of a described stack that cfn-clone copies.
+type Stack struct {
+	StackId           string
+	StackName         string
+	StackStatus       string
+	StackStatusReason string
+	Parameters        map[string]string
+	Tags              map[string]string
+	// ResolvedParameters holds the values SSM parameter types resolved to
+	// when the stack was last deployed, keyed like Parameters.
+	ResolvedParameters map[string]string
+	StackSettings
+}
+
+// StackSettings are the stack level settings carried over to a clone besides
+// its template, parameters and tags. DescribeStack leaves StackPolicyBody
+// empty, as the policy is read with GetStackPolicy.
+type StackSettings struct {
+	NotificationARNs            []string
+	RoleARN                     string
+	StackPolicyBody             string
+	TimeoutInMinutes            int64
+	EnableTerminationProtection bool
+	RollbackConfiguration       *RollbackConfiguration
+}
+
+// RollbackConfiguration holds the alarms CloudFormation monitors to roll back
+// a stack operation.
+type RollbackConfiguration struct {
+	MonitoringTimeInMinutes int64
+	RollbackTriggers        []RollbackTrigger
+}
+
+// RollbackTrigger is a single alarm monitored during a stack operation.
+type RollbackTrigger struct {
+	Arn  string
+	Type string
+}
+
+// StackInput describes a stack to create or update.
+type StackInput struct {
+	StackName    string
+	TemplateBody string
+	// TemplateURL, when set, points at a staged copy of TemplateBody to
+	// use in its place.
+	TemplateURL string
+	Parameters  map[string]string
+	// PreviousParameters are keys of Parameters sent as UsePreviousValue,
+	// keeping the value the stack already has.
+	PreviousParameters []string
+	Capabilities       []string
+	Tags               map[string]string
+	StackSettings
+}
+
+// parameterValues returns the parameters sent with their values, leaving out
+// the PreviousParameters.
+func (i *StackInput) parameterValues() map[string]string {
+	values := map[string]string{}
+	for k, v := range i.Parameters {
+		values[k] = v
+	}
+	for _, k := range i.PreviousParameters {
+		delete(values, k)
+	}
+	return values
+}
+
+// ChangeSetInput describes a change set to create against a stack.
+type ChangeSetInput struct {
+	StackInput
+	ChangeSetName string
+	ChangeSetType string
+}
+
+// StackEvent is a single entry from a stack's event history.
+type StackEvent struct {
+	EventId              string
+	StackName            string
+	LogicalResourceId    string
+	ResourceType         string
+	ResourceStatus       string
+	ResourceStatusReason string
+	Timestamp            time.Time
+}
+
+// ChangeSet is a described change set and the resource changes it holds.
+type ChangeSet struct {
+	ChangeSetId     string
+	StackId         string
+	ChangeSetName   string
+	Status          string
+	StatusReason    string
+	ExecutionStatus string
+	Changes         []ResourceChange
+}
+
+// ResourceChange is a single resource level change within a change set.
+type ResourceChange struct {
+	Action             string
+	LogicalResourceId  string
+	PhysicalResourceId string
+	ResourceType       string
+	Replacement        string
+}
+
+// clientConfig selects where and as whom a client talks to CloudFormation.
+// Empty fields fall back to the usual aws environment and config files.
+type clientConfig struct {
+	EndpointURL string
+	Region      string
+	Profile     string
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+}
+
+// newClient returns a CloudFormationClient for backend.
+func newClient(backend string, config clientConfig, out io.Writer) (CloudFormationClient, error) {
+	if backend == sdkBackend {
+		return newAwsSdkClient(config)
+	}
+
+	return newAwsCliClient(config, out), nil
+}
+
+// newClients returns the clients reading the source stack and creating the
+// new stack, which differ when cloning across regions, profiles or accounts.
+func newClients(options *options, out io.Writer) (CloudFormationClient, CloudFormationClient, error) {
+	source, err := newClient(options.Backend, clientConfig{
+		EndpointURL: options.EndpointURL,
+		Region:      options.SourceRegion,
+		Profile:     options.SourceProfile,
+		RoleARN:     options.SourceRoleARN,
+		ExternalID:  options.SourceExternalID,
+		SessionName: options.RoleSessionName,
+	}, out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := newClient(options.Backend, clientConfig{
+		EndpointURL: options.EndpointURL,
+		Region:      options.TargetRegion,
+		Profile:     options.TargetProfile,
+		RoleARN:     options.TargetRoleARN,
+		ExternalID:  options.TargetExternalID,
+		SessionName: options.RoleSessionName,
+	}, out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
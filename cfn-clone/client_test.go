@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+)
+
+// fakeClient is an in memory CloudFormationClient used to exercise the clone
+// flow without an AWS account.
+type fakeClient struct {
+	stacks     map[string]*Stack
+	templates  map[string]string
+	policies   map[string]string
+	events     map[string][]StackEvent
+	changeSets map[string]*ChangeSet
+	ssm        map[string]string
+	secrets    map[string]string
+	objects    map[string]string
+	errors     map[string]error
+
+	created          []*StackInput
+	updated          []*StackInput
+	deleted          []string
+	createdChangeSet []*ChangeSetInput
+	executed         []string
+	deletedChangeSet []string
+	setPolicies      map[string]string
+	protected        map[string]bool
+
+	// nextChangeSet, when set, is how the next created change set is
+	// described instead of as complete with no changes.
+	nextChangeSet *ChangeSet
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		stacks:     map[string]*Stack{},
+		templates:  map[string]string{},
+		policies:   map[string]string{},
+		events:     map[string][]StackEvent{},
+		changeSets: map[string]*ChangeSet{},
+		ssm:        map[string]string{},
+		secrets:    map[string]string{},
+		objects:    map[string]string{},
+		errors:     map[string]error{},
+
+		setPolicies: map[string]string{},
+		protected:   map[string]bool{},
+	}
+}
+
+func (c *fakeClient) DescribeStack(name string) (*Stack, error) {
+	if err := c.errors["DescribeStack"]; err != nil {
+		return nil, err
+	}
+
+	s, ok := c.stacks[name]
+	if !ok {
+		return nil, fmt.Errorf("Stack with id %s does not exist", name)
+	}
+
+	params := map[string]string{}
+	for k, v := range s.Parameters {
+		params[k] = v
+	}
+	copied := *s
+	copied.Parameters = params
+
+	return &copied, nil
+}
+
+func (c *fakeClient) GetTemplate(name string) (string, error) {
+	if err := c.errors["GetTemplate"]; err != nil {
+		return "", err
+	}
+
+	t, ok := c.templates[name]
+	if !ok {
+		return "", fmt.Errorf("Stack with id %s does not exist", name)
+	}
+
+	return t, nil
+}
+
+func (c *fakeClient) GetStackPolicy(name string) (string, error) {
+	if err := c.errors["GetStackPolicy"]; err != nil {
+		return "", err
+	}
+
+	return c.policies[name], nil
+}
+
+func (c *fakeClient) SetStackPolicy(name string, body string) error {
+	c.setPolicies[name] = body
+	return c.errors["SetStackPolicy"]
+}
+
+func (c *fakeClient) UpdateTerminationProtection(name string, enable bool) error {
+	c.protected[name] = enable
+	return c.errors["UpdateTerminationProtection"]
+}
+
+func (c *fakeClient) CreateStack(input *StackInput) (string, error) {
+	if err := c.errors["CreateStack"]; err != nil {
+		return "", err
+	}
+
+	c.created = append(c.created, input)
+	return "arn:aws:cloudformation:us-east-1:123456789012:stack/" + input.StackName + "/1", nil
+}
+
+func (c *fakeClient) UpdateStack(input *StackInput) (string, error) {
+	if err := c.errors["UpdateStack"]; err != nil {
+		return "", err
+	}
+
+	c.updated = append(c.updated, input)
+	return "arn:aws:cloudformation:us-east-1:123456789012:stack/" + input.StackName + "/1", nil
+}
+
+func (c *fakeClient) DeleteStack(name string) error {
+	c.deleted = append(c.deleted, name)
+	return c.errors["DeleteStack"]
+}
+
+func (c *fakeClient) DescribeStackEvents(name string, seen map[string]bool) ([]StackEvent, error) {
+	if err := c.errors["DescribeStackEvents"]; err != nil {
+		return nil, err
+	}
+
+	return c.events[name], nil
+}
+
+func (c *fakeClient) CreateChangeSet(input *ChangeSetInput) (string, error) {
+	if err := c.errors["CreateChangeSet"]; err != nil {
+		return "", err
+	}
+
+	c.createdChangeSet = append(c.createdChangeSet, input)
+	if _, ok := c.changeSets[input.ChangeSetName]; !ok {
+		cs := &ChangeSet{
+			ChangeSetName: input.ChangeSetName,
+			StackId:       "arn:aws:cloudformation:us-east-1:123456789012:stack/" + input.StackName + "/1",
+			Status:        "CREATE_COMPLETE",
+		}
+		if c.nextChangeSet != nil {
+			cs, c.nextChangeSet = c.nextChangeSet, nil
+		}
+		c.changeSets[input.ChangeSetName] = cs
+	}
+	return "arn:aws:cloudformation:us-east-1:123456789012:changeSet/" + input.ChangeSetName + "/1", nil
+}
+
+func (c *fakeClient) DescribeChangeSet(stack string, changeSet string) (*ChangeSet, error) {
+	if err := c.errors["DescribeChangeSet"]; err != nil {
+		return nil, err
+	}
+
+	cs, ok := c.changeSets[changeSet]
+	if !ok {
+		return nil, fmt.Errorf("ChangeSet [%s] does not exist", changeSet)
+	}
+
+	return cs, nil
+}
+
+func (c *fakeClient) ExecuteChangeSet(stack string, changeSet string) error {
+	c.executed = append(c.executed, changeSet)
+	return c.errors["ExecuteChangeSet"]
+}
+
+func (c *fakeClient) DeleteChangeSet(stack string, changeSet string) error {
+	c.deletedChangeSet = append(c.deletedChangeSet, changeSet)
+	return c.errors["DeleteChangeSet"]
+}
+
+func (c *fakeClient) GetParameter(name string) (string, error) {
+	v, ok := c.ssm[name]
+	if !ok {
+		return "", fmt.Errorf("ParameterNotFound: %s", name)
+	}
+	return v, nil
+}
+
+func (c *fakeClient) GetSecretValue(id string) (string, error) {
+	v, ok := c.secrets[id]
+	if !ok {
+		return "", fmt.Errorf("ResourceNotFoundException: %s", id)
+	}
+	return v, nil
+}
+
+func (c *fakeClient) PutTemplate(bucket string, key string, body string, kmsKeyID string) (string, error) {
+	if err := c.errors["PutTemplate"]; err != nil {
+		return "", err
+	}
+
+	c.objects[bucket+"/"+key] = body
+	return templateURL(bucket, "", key), nil
+}
+
+func (c *fakeClient) DeleteTemplate(bucket string, key string) error {
+	delete(c.objects, bucket+"/"+key)
+	return nil
+}
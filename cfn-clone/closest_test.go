@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+var levenshteinTcs = []struct {
+	s    string
+	t    string
+	dist int
+}{
+	{"", "abc", 3},
+	{"abc", "", 3},
+	{"abc", "abc", 0},
+	{"a", "ab", 1},
+	{"InstanceTpye", "InstanceType", 2},
+	{"kitten", "sitting", 3},
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range levenshteinTcs {
+		if d := levenshtein(tc.s, tc.t); d != tc.dist {
+			t.Fatalf("Expected %d got %d for '%s' and '%s'", tc.dist, d, tc.s, tc.t)
+		}
+	}
+}
+
+func TestClosestChoice(t *testing.T) {
+	c, d := closestChoice("InstanceTpye", []string{"KeyName", "InstanceType", "ImageId"})
+	if c != "InstanceType" || d != 2 {
+		t.Fatalf("Expected 'InstanceType' at 2 got '%s' at %d", c, d)
+	}
+
+	if c, _ := closestChoice("foo", []string{}); c != "" {
+		t.Fatalf("Expected no choice got '%s'", c)
+	}
+}
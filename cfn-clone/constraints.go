@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// isListParameterType reports whether values of type t are comma separated.
+func isListParameterType(t string) bool {
+	return t == "CommaDelimitedList" || strings.HasPrefix(t, "List<")
+}
+
+// isSSMParameterType reports whether t is resolved from the SSM Parameter Store.
+func isSSMParameterType(t string) bool {
+	return strings.HasPrefix(t, "AWS::SSM::Parameter::")
+}
+
+// validateParameterConstraints checks value against the constraints the
+// template declares for it, returning every violation found.
+func validateParameterConstraints(p templateParameter, value string) []string {
+	violations := []string{}
+
+	if isSSMParameterType(p.Type) {
+		return violations
+	}
+
+	values := []string{value}
+	if isListParameterType(p.Type) {
+		values = strings.Split(value, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+	}
+
+	numeric := p.Type == "Number" || p.Type == "List<Number>"
+
+	for _, v := range values {
+		if numeric {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("'%s' is not a number", v))
+				continue
+			}
+			if p.MinValue != nil && n < float64(*p.MinValue) {
+				violations = append(violations, fmt.Sprintf("'%s' is less than MinValue %v", v, float64(*p.MinValue)))
+			}
+			if p.MaxValue != nil && n > float64(*p.MaxValue) {
+				violations = append(violations, fmt.Sprintf("'%s' is greater than MaxValue %v", v, float64(*p.MaxValue)))
+			}
+		}
+
+		if len(p.AllowedValues) > 0 && !isAllowedValue(p.AllowedValues, v) {
+			violations = append(violations, fmt.Sprintf("'%s' is not one of %s", v, allowedValuesString(p.AllowedValues)))
+		}
+	}
+
+	if p.Type == "String" || p.Type == "" {
+		length := utf8.RuneCountInString(value)
+		if p.MinLength != nil && float64(length) < float64(*p.MinLength) {
+			violations = append(violations, fmt.Sprintf("length %d is shorter than MinLength %v", length, float64(*p.MinLength)))
+		}
+		if p.MaxLength != nil && float64(length) > float64(*p.MaxLength) {
+			violations = append(violations, fmt.Sprintf("length %d is longer than MaxLength %v", length, float64(*p.MaxLength)))
+		}
+		if p.AllowedPattern != "" {
+			// AllowedPattern must match the entire value
+			re, err := regexp.Compile("^(?:" + p.AllowedPattern + ")$")
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("AllowedPattern '%s' is not a valid regular expression", p.AllowedPattern))
+			} else if !re.MatchString(value) {
+				violations = append(violations, fmt.Sprintf("'%s' does not match AllowedPattern '%s'", value, p.AllowedPattern))
+			}
+		}
+	}
+
+	return violations
+}
+
+func allowedValueString(v interface{}) string {
+	s, err := stringValue(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return s
+}
+
+func isAllowedValue(allowed []interface{}, v string) bool {
+	for _, a := range allowed {
+		if allowedValueString(a) == v {
+			return true
+		}
+	}
+	return false
+}
+
+func allowedValuesString(allowed []interface{}) string {
+	values := []string{}
+	for _, a := range allowed {
+		values = append(values, allowedValueString(a))
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// validateParameters checks every merged parameter against the constraints
+// of the template and reports all violations at once.
+func validateParameters(t *cfnTemplate, params map[string]string) error {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	messages := []string{}
+	for _, k := range keys {
+		p, ok := t.Parameters[k]
+		if !ok || params[k] == redactedValue || isResolverReference(params[k]) {
+			continue
+		}
+
+		violations := validateParameterConstraints(p, params[k])
+		if len(violations) == 0 {
+			continue
+		}
+
+		msg := "  " + k + ": " + strings.Join(violations, "; ")
+		if p.ConstraintDescription != "" {
+			msg += ". " + p.ConstraintDescription
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(messages) > 0 {
+		return errors.New("Parameters do not satisfy the template constraints:\n" + strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func number(n float64) *cfnNumber {
+	c := cfnNumber(n)
+	return &c
+}
+
+var parameterConstraintsTcs = []struct {
+	param      templateParameter
+	value      string
+	violations int
+}{
+	{templateParameter{Type: "String"}, "anything", 0},
+	{templateParameter{Type: "String", AllowedValues: []interface{}{"a", "b"}}, "a", 0},
+	{templateParameter{Type: "String", AllowedValues: []interface{}{"a", "b"}}, "c", 1},
+	{templateParameter{Type: "String", AllowedPattern: "[a-z]+"}, "abc", 0},
+	{templateParameter{Type: "String", AllowedPattern: "[a-z]+"}, "abc1", 1},
+	{templateParameter{Type: "String", MinLength: number(2), MaxLength: number(3)}, "ab", 0},
+	{templateParameter{Type: "String", MinLength: number(2), MaxLength: number(3)}, "a", 1},
+	{templateParameter{Type: "String", MinLength: number(2), MaxLength: number(3)}, "abcd", 1},
+	{templateParameter{Type: "String", MaxLength: number(1), AllowedPattern: "[0-9]"}, "ab", 2},
+	{templateParameter{Type: "Number"}, "12.5", 0},
+	{templateParameter{Type: "Number"}, "twelve", 1},
+	{templateParameter{Type: "Number", MinValue: number(1), MaxValue: number(10)}, "0", 1},
+	{templateParameter{Type: "Number", MinValue: number(1), MaxValue: number(10)}, "11", 1},
+	{templateParameter{Type: "Number", AllowedValues: []interface{}{float64(80), float64(443)}}, "443", 0},
+	{templateParameter{Type: "List<Number>", MaxValue: number(10)}, "1, 2,30", 1},
+	{templateParameter{Type: "CommaDelimitedList", AllowedValues: []interface{}{"a", "b"}}, "a,b,c", 1},
+	{templateParameter{Type: "AWS::SSM::Parameter::Value<String>", AllowedPattern: "x"}, "/app/name", 0},
+}
+
+func TestValidateParameterConstraints(t *testing.T) {
+	for _, tc := range parameterConstraintsTcs {
+		violations := validateParameterConstraints(tc.param, tc.value)
+		if len(violations) != tc.violations {
+			t.Fatalf("Expected %d violations got '%v' for '%s' against '%+v'", tc.violations, violations, tc.value, tc.param)
+		}
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	tmpl, err := parseTemplate(`{
+		"Parameters": {
+			"InstanceType": {"Type": "String", "AllowedValues": ["t2.micro", "m5.large"], "ConstraintDescription": "Must be a valid EC2 instance type."},
+			"Port": {"Type": "Number", "MinValue": "1", "MaxValue": 65535},
+			"Password": {"Type": "String", "NoEcho": true, "MinLength": 8}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	err = validateParameters(tmpl, map[string]string{
+		"InstanceType": "m5.huge",
+		"Port":         "0",
+		"Password":     "****",
+		"Undeclared":   "x",
+	})
+	if err == nil {
+		t.Fatalf("Expected constraint violations")
+	}
+
+	for _, s := range []string{"InstanceType: 'm5.huge' is not one of [t2.micro, m5.large]. Must be a valid EC2 instance type.", "Port: '0' is less than MinValue 1"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("Expected '%s' in '%v'", s, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "Password") {
+		t.Fatalf("Expected masked NoEcho values to be skipped got '%v'", err)
+	}
+
+	if err = validateParameters(tmpl, map[string]string{"InstanceType": "t2.micro", "Port": "443"}); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+)
+
+// diffContext is how many unchanged lines are shown around each change.
+const diffContext = 2
+
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+// lineDiff compares two texts line by line. It returns the removed and added
+// lines prefixed with "- " and "+ ", with a few unchanged lines around each
+// change prefixed with "  ", and "..." standing in for the unchanged lines
+// left out. Texts that are the same have no diff.
+func lineDiff(from string, to string) []string {
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	changed := []int{}
+	for i, l := range lines {
+		if l.Op != valueUnchanged {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	show := map[int]bool{}
+	for _, i := range changed {
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			show[j] = true
+		}
+	}
+
+	diff := []string{}
+	elided := false
+	for i, l := range lines {
+		if !show[i] {
+			elided = true
+			continue
+		}
+		if elided && len(diff) > 0 {
+			diff = append(diff, "...")
+		}
+		elided = false
+
+		prefix := "  "
+		if l.Op != valueUnchanged {
+			prefix = l.Op + " "
+		}
+		diff = append(diff, prefix+l.Text)
+	}
+
+	return diff
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines lines up a and b along their longest common subsequence, after
+// setting aside the lines they start and end with in common.
+func diffLines(a []string, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// common[i][j] is the length of the longest common subsequence of
+	// ma[i:] and mb[j:].
+	common := make([][]int, len(ma)+1)
+	for i := range common {
+		common[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{valueUnchanged, l})
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			lines = append(lines, diffLine{valueUnchanged, ma[i]})
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, diffLine{valueRemoved, ma[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{valueAdded, mb[j]})
+			j++
+		}
+	}
+
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{valueUnchanged, l})
+	}
+
+	return lines
+}
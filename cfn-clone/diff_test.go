@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var lineDiffTcs = []struct {
+	from     string
+	to       string
+	expected []string
+}{
+	{"a\nb\n", "a\nb\n", nil},
+	{"a\nb\nc\n", "a\nx\nc\n", []string{"  a", "- b", "+ x", "  c"}},
+	{"", "a\n", []string{"+ a"}},
+	{"a\r\nb\r\n", "a\nb", nil},
+	{
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		[]string{"  8", "  9", "+ 10"},
+	},
+	{
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		"0\n1\n2\n3\n4\n5\n6\n7\n8\n",
+		[]string{"+ 0", "  1", "  2", "...", "  7", "  8", "- 9"},
+	},
+}
+
+func TestLineDiff(t *testing.T) {
+	for _, tc := range lineDiffTcs {
+		result := lineDiff(tc.from, tc.to)
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Fatalf("Expected '%v' got '%v' for '%s' -> '%s'", strings.Join(tc.expected, "|"), strings.Join(result, "|"), tc.from, tc.to)
+		}
+	}
+}
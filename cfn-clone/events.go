@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// stackPollInterval is how long to wait between polls of a stack's events.
+var stackPollInterval = 5 * time.Second
+
+func stackEventLine(e StackEvent) string {
+	line := fmt.Sprintf("%s  %-30s %-40s %s",
+		e.Timestamp.UTC().Format(time.RFC3339),
+		e.LogicalResourceId,
+		e.ResourceType,
+		e.ResourceStatus,
+	)
+
+	if e.ResourceStatusReason != "" {
+		line += "  " + e.ResourceStatusReason
+	}
+
+	return line
+}
+
+func isStackEvent(e StackEvent) bool {
+	return e.ResourceType == "AWS::CloudFormation::Stack" && e.LogicalResourceId == e.StackName
+}
+
+func stackStatusInProgress(status string) bool {
+	return strings.HasSuffix(status, "_IN_PROGRESS")
+}
+
+func stackStatusSucceeded(status string) bool {
+	switch status {
+	case "CREATE_COMPLETE", "UPDATE_COMPLETE", "IMPORT_COMPLETE":
+		return true
+	}
+	return false
+}
+
+// reachesSeen reports whether any of events is in seen, so older pages of
+// events need not be fetched.
+func reachesSeen(events []StackEvent, seen map[string]bool) bool {
+	for _, e := range events {
+		if seen[e.EventId] {
+			return true
+		}
+	}
+	return false
+}
+
+// stackEventIds returns the IDs of the events stack already has, so waiting
+// on an update of an existing stack skips those of its earlier operations.
+func stackEventIds(client CloudFormationClient, stack string) (map[string]bool, error) {
+	events, err := client.DescribeStackEvents(stack, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get stack events. %s", err.Error())
+	}
+
+	ids := map[string]bool{}
+	for _, e := range events {
+		ids[e.EventId] = true
+	}
+	return ids, nil
+}
+
+// waitForStack polls the events of stack until the stack itself reaches a
+// terminal status, writing every new event to out as it is seen. Events in
+// seen, from before the operation waited on, are skipped. It returns an
+// error carrying the first failure reason if the stack did not succeed.
+func waitForStack(client CloudFormationClient, stack string, seen map[string]bool, out io.Writer) error {
+	if seen == nil {
+		seen = map[string]bool{}
+	}
+	firstFailure := ""
+
+	for {
+		events, err := client.DescribeStackEvents(stack, seen)
+		if err != nil {
+			return fmt.Errorf("Unable to get stack events. %s", err.Error())
+		}
+
+		// events are returned newest first
+		for i := len(events) - 1; i >= 0; i-- {
+			e := events[i]
+			if seen[e.EventId] {
+				continue
+			}
+			seen[e.EventId] = true
+
+			fmt.Fprintln(out, stackEventLine(e))
+
+			if firstFailure == "" && strings.HasSuffix(e.ResourceStatus, "_FAILED") && e.ResourceStatusReason != "" {
+				firstFailure = e.LogicalResourceId + ": " + e.ResourceStatusReason
+			}
+
+			if !isStackEvent(e) || stackStatusInProgress(e.ResourceStatus) {
+				continue
+			}
+
+			if stackStatusSucceeded(e.ResourceStatus) {
+				return nil
+			}
+
+			if firstFailure == "" {
+				firstFailure = e.ResourceStatusReason
+			}
+			return fmt.Errorf("Stack '%s' ended in %s. %s", e.StackName, e.ResourceStatus, firstFailure)
+		}
+
+		time.Sleep(stackPollInterval)
+	}
+}
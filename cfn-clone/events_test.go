@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pollingClient returns one more batch of events on each poll, newest first,
+// like describe-stack-events does while a stack is being created.
+type pollingClient struct {
+	*fakeClient
+	batches [][]StackEvent
+	polls   int
+}
+
+func (c *pollingClient) DescribeStackEvents(name string, seen map[string]bool) ([]StackEvent, error) {
+	events := []StackEvent{}
+	for i := 0; i <= c.polls && i < len(c.batches); i++ {
+		events = append(c.batches[i], events...)
+	}
+	c.polls++
+	return events, nil
+}
+
+func stackEvent(id string, logicalId string, status string, reason string) StackEvent {
+	e := StackEvent{
+		EventId:              id,
+		StackName:            "clone",
+		LogicalResourceId:    logicalId,
+		ResourceType:         "AWS::S3::Bucket",
+		ResourceStatus:       status,
+		ResourceStatusReason: reason,
+		Timestamp:            time.Date(2014, 10, 14, 0, 0, 0, 0, time.UTC),
+	}
+	if logicalId == "clone" {
+		e.ResourceType = "AWS::CloudFormation::Stack"
+	}
+	return e
+}
+
+var waitForStackTcs = []struct {
+	batches       [][]StackEvent
+	resultIsError bool
+	reason        string
+}{
+	{
+		[][]StackEvent{
+			{stackEvent("1", "clone", "CREATE_IN_PROGRESS", "User Initiated")},
+			{stackEvent("3", "Bucket", "CREATE_COMPLETE", ""), stackEvent("2", "Bucket", "CREATE_IN_PROGRESS", "")},
+			{stackEvent("4", "clone", "CREATE_COMPLETE", "")},
+		},
+		false,
+		"",
+	},
+	{
+		[][]StackEvent{
+			{stackEvent("1", "clone", "CREATE_IN_PROGRESS", "User Initiated")},
+			{stackEvent("3", "Bucket", "CREATE_FAILED", "Bucket already exists"), stackEvent("2", "Bucket", "CREATE_IN_PROGRESS", "")},
+			{stackEvent("5", "clone", "ROLLBACK_COMPLETE", ""), stackEvent("4", "clone", "ROLLBACK_IN_PROGRESS", "The following resource(s) failed to create: [Bucket].")},
+		},
+		true,
+		"Bucket: Bucket already exists",
+	},
+}
+
+func TestWaitForStack(t *testing.T) {
+	stackPollInterval = time.Millisecond
+
+	for _, tc := range waitForStackTcs {
+		c := &pollingClient{fakeClient: newFakeClient(), batches: tc.batches}
+
+		var out bytes.Buffer
+		err := waitForStack(c, "clone", nil, &out)
+		if (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v'", tc.resultIsError, err)
+		}
+
+		if err != nil && !strings.Contains(err.Error(), tc.reason) {
+			t.Fatalf("Expected '%s' in '%v'", tc.reason, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		total := 0
+		for _, b := range tc.batches {
+			total += len(b)
+		}
+		if len(lines) != total {
+			t.Fatalf("Expected each of the %d events once got '%s'", total, out.String())
+		}
+	}
+}
+
+func TestWaitForStackSkipsEarlierEvents(t *testing.T) {
+	stackPollInterval = time.Millisecond
+
+	c := &pollingClient{fakeClient: newFakeClient(), batches: [][]StackEvent{
+		{stackEvent("2", "clone", "CREATE_COMPLETE", ""), stackEvent("1", "clone", "CREATE_IN_PROGRESS", "User Initiated")},
+		{stackEvent("3", "clone", "UPDATE_IN_PROGRESS", "User Initiated")},
+		{stackEvent("5", "clone", "UPDATE_ROLLBACK_COMPLETE", ""), stackEvent("4", "Bucket", "UPDATE_FAILED", "Access denied")},
+	}}
+
+	seen, err := stackEventIds(c, "clone")
+	if err != nil || len(seen) != 2 {
+		t.Fatalf("Expected the 2 earlier events got '%v' (%v)", seen, err)
+	}
+
+	var out bytes.Buffer
+	err = waitForStack(c, "clone", seen, &out)
+	if err == nil || !strings.Contains(err.Error(), "UPDATE_ROLLBACK_COMPLETE. Bucket: Access denied") {
+		t.Fatalf("Expected the update to fail got '%v'", err)
+	}
+
+	if strings.Contains(out.String(), "CREATE_COMPLETE") {
+		t.Fatalf("Expected the earlier events to be skipped got '%s'", out.String())
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exportAffixes returns the prefix and suffix added to export names, which
+// default to a suffix of the new stack name.
+func exportAffixes(options *options) (string, string) {
+	if options.ExportPrefix == "" && options.ExportSuffix == "" {
+		return "", "-" + options.NewName
+	}
+	return options.ExportPrefix, options.ExportSuffix
+}
+
+// renameExportsFor reports whether export names are renamed for a clone
+// across scope. Export names are unique per account and region, so by
+// default they are only renamed when the clone stays in the source's, unless
+// a prefix or suffix is given.
+func renameExportsFor(options *options, scope rewriteScope) bool {
+	if options.NoExportRename {
+		return false
+	}
+	if options.ExportPrefix != "" || options.ExportSuffix != "" {
+		return true
+	}
+	return !scope.crossesRegion() && !scope.crossesAccount()
+}
+
+// renameExports adds prefix and suffix to every export name in the template
+// that would collide with the source stack's exports. Names built from
+// AWS::StackName are already unique to the stack and are left alone. The
+// body is returned as written when nothing is renamed.
+func renameExports(body string, prefix string, suffix string) (string, []rewrite, error) {
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("Unable to rename exports. %s", err.Error())
+	}
+
+	outputs := mappingValue(b.root(), "Outputs")
+	if outputs == nil || outputs.Kind != yaml.MappingNode {
+		return body, nil, nil
+	}
+
+	keys := []string{}
+	names := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(outputs.Content); i += 2 {
+		name := mappingValue(mappingValue(outputs.Content[i+1], "Export"), "Name")
+		if name != nil && !referencesStackName(name) {
+			keys = append(keys, outputs.Content[i].Value)
+			names[outputs.Content[i].Value] = name
+		}
+	}
+	sort.Strings(keys)
+
+	renames := []rewrite{}
+	for _, k := range keys {
+		n := names[k]
+		from := nameString(n)
+		reason := affixName(n, prefix, suffix)
+		renames = append(renames, rewrite{Key: k, From: from, To: nameString(n), Reason: reason})
+	}
+
+	if len(renames) == 0 {
+		return body, nil, nil
+	}
+
+	renamed, err := b.String()
+	if err != nil {
+		return "", nil, fmt.Errorf("Unable to rename exports. %s", err.Error())
+	}
+
+	return renamed, renames, nil
+}
+
+// affixName adds prefix and suffix to the name n in place, returning how it
+// was renamed. Literals and Fn::Sub strings are edited, and any other
+// function is wrapped in an Fn::Join.
+func affixName(n *yaml.Node, prefix string, suffix string) string {
+	fn, isFunction := shortFormFunction(n.Tag)
+
+	switch {
+	case n.Kind == yaml.ScalarNode && !isFunction:
+		setString(n, prefix+n.Value+suffix)
+		return "literal"
+	case fn == "Fn::Sub":
+		if affixSub(n, prefix, suffix) {
+			return "Fn::Sub"
+		}
+	case !isFunction && n.Kind == yaml.MappingNode && len(n.Content) == 2 && n.Content[0].Value == "Fn::Sub":
+		if affixSub(n.Content[1], prefix, suffix) {
+			return "Fn::Sub"
+		}
+	}
+
+	wrapped := *n
+	parts := []*yaml.Node{}
+	if prefix != "" {
+		parts = append(parts, stringNode(prefix))
+	}
+	parts = append(parts, &wrapped)
+	if suffix != "" {
+		parts = append(parts, stringNode(suffix))
+	}
+
+	*n = yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			stringNode("Fn::Join"),
+			{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{
+				stringNode(""),
+				{Kind: yaml.SequenceNode, Tag: "!!seq", Content: parts},
+			}},
+		},
+	}
+	return "wrapped in Fn::Join"
+}
+
+// affixSub edits the string of an Fn::Sub, in either its string or its
+// [string, variables] form.
+func affixSub(n *yaml.Node, prefix string, suffix string) bool {
+	if n.Kind == yaml.SequenceNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	n.Value = prefix + n.Value + suffix
+	return true
+}
+
+func stringNode(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
+
+// referencesStackName reports whether n is built from the stack's own name,
+// through a Ref to AWS::StackName or ${AWS::StackName} in an Fn::Sub.
+func referencesStackName(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return strings.Contains(n.Value, "${AWS::StackName}") || (n.Tag == "!Ref" && n.Value == "AWS::StackName")
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "Ref" && n.Content[i+1].Value == "AWS::StackName" {
+				return true
+			}
+		}
+	case yaml.AliasNode:
+		return referencesStackName(n.Alias)
+	}
+
+	for _, c := range n.Content {
+		if referencesStackName(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameString describes a name for the reports, as the literal name or the
+// function building it as compact JSON.
+func nameString(n *yaml.Node) string {
+	v, err := nodeValue(n)
+	if err != nil {
+		return n.Value
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return n.Value
+	}
+	return string(j)
+}
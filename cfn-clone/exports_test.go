@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exportsTemplate = `{
+  "Resources": {},
+  "Outputs": {
+    "Vpc": {"Value": "x", "Export": {"Name": "vpc-id"}},
+    "Subnets": {"Value": "x", "Export": {"Name": {"Fn::Sub": "${Env}-subnets"}}},
+    "Db": {"Value": "x", "Export": {"Name": {"Fn::Join": ["-", [{"Ref": "Env"}, "db"]]}}},
+    "Queue": {"Value": "x", "Export": {"Name": {"Fn::Sub": "${AWS::StackName}-queue"}}},
+    "Plain": {"Value": "x"}
+  }
+}`
+
+const exportsYAMLTemplate = `Outputs:
+  Vpc:
+    Value: x
+    Export:
+      Name: vpc-id
+  Subnets:
+    Value: x
+    Export:
+      Name: !Sub ['${X}-subnets', {X: !Ref Env}]
+  Db:
+    Value: x
+    Export:
+      Name: !Join ['-', [!Ref Env, db]]
+  Queue:
+    Value: x
+    Export:
+      Name: !Join ['-', [!Ref 'AWS::StackName', queue]]
+`
+
+// exportNames returns the export names of a template as plain values.
+func exportNames(t *testing.T, body string) map[string]interface{} {
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		t.Fatalf("Unable to parse '%s': %v", body, err)
+	}
+	v, _ := b.value()
+
+	names := map[string]interface{}{}
+	for k, o := range v.(map[string]interface{})["Outputs"].(map[string]interface{}) {
+		if e, ok := o.(map[string]interface{})["Export"]; ok {
+			names[k] = e.(map[string]interface{})["Name"]
+		}
+	}
+	return names
+}
+
+func TestRenameExports(t *testing.T) {
+	body, renames, err := renameExports(exportsTemplate, "", "-clone")
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]interface{}{
+		"Vpc":     "vpc-id-clone",
+		"Subnets": map[string]interface{}{"Fn::Sub": "${Env}-subnets-clone"},
+		"Db": map[string]interface{}{"Fn::Join": []interface{}{"", []interface{}{
+			map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{map[string]interface{}{"Ref": "Env"}, "db"}}},
+			"-clone",
+		}}},
+		"Queue": map[string]interface{}{"Fn::Sub": "${AWS::StackName}-queue"},
+	}
+	if result := exportNames(t, body); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+
+	expectedRenames := []rewrite{
+		{Key: "Db", From: `{"Fn::Join":["-",[{"Ref":"Env"},"db"]]}`, To: `{"Fn::Join":["",[{"Fn::Join":["-",[{"Ref":"Env"},"db"]]},"-clone"]]}`, Reason: "wrapped in Fn::Join"},
+		{Key: "Subnets", From: `{"Fn::Sub":"${Env}-subnets"}`, To: `{"Fn::Sub":"${Env}-subnets-clone"}`, Reason: "Fn::Sub"},
+		{Key: "Vpc", From: "vpc-id", To: "vpc-id-clone", Reason: "literal"},
+	}
+	if !reflect.DeepEqual(renames, expectedRenames) {
+		t.Fatalf("Expected '%v' got '%v'", expectedRenames, renames)
+	}
+}
+
+func TestRenameExportsYAML(t *testing.T) {
+	body, renames, err := renameExports(exportsYAMLTemplate, "staging-", "")
+	if err != nil || len(renames) != 3 {
+		t.Fatalf("Expected 3 renames got '%v' with '%v'", renames, err)
+	}
+
+	expected := map[string]interface{}{
+		"Vpc":     "staging-vpc-id",
+		"Subnets": map[string]interface{}{"Fn::Sub": []interface{}{"staging-${X}-subnets", map[string]interface{}{"X": map[string]interface{}{"Ref": "Env"}}}},
+		"Db": map[string]interface{}{"Fn::Join": []interface{}{"", []interface{}{
+			"staging-",
+			map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{map[string]interface{}{"Ref": "Env"}, "db"}}},
+		}}},
+		"Queue": map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{map[string]interface{}{"Ref": "AWS::StackName"}, "queue"}}},
+	}
+	if result := exportNames(t, body); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+
+	if templateFormat(body) != yamlFormat {
+		t.Fatalf("Expected the template to stay YAML got '%s'", body)
+	}
+}
+
+func TestRenameExportsLeavesTemplateWithoutExports(t *testing.T) {
+	body := `{"Resources": {},   "Outputs": {"A": {"Value": "x"}}}`
+	result, renames, err := renameExports(body, "", "-clone")
+	if result != body || len(renames) != 0 || err != nil {
+		t.Fatalf("Expected the template as written got '%s' '%v' '%v'", result, renames, err)
+	}
+}
+
+func TestRenameExportsJSONEscapes(t *testing.T) {
+	body := `{"Resources": {"Topic": {"Type": "AWS::SNS::Topic", "Properties": {"DisplayName": "a\/b"}}}, "Outputs": {"A": {"Value": 1.5e3, "Export": {"Name": "a"}}}}`
+	result, renames, err := renameExports(body, "", "-clone")
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if len(renames) != 1 {
+		t.Fatalf("Expected one rename got '%v'", renames)
+	}
+
+	for _, s := range []string{`"DisplayName": "a/b"`, `"Value": 1.5e3`, `"Name": "a-clone"`} {
+		if !strings.Contains(result, s) {
+			t.Fatalf("Expected '%s' in '%s'", s, result)
+		}
+	}
+}
+
+var exportAffixesTcs = []struct {
+	opts   *options
+	prefix string
+	suffix string
+}{
+	{&options{NewName: "clone"}, "", "-clone"},
+	{&options{NewName: "clone", ExportPrefix: "dr-"}, "dr-", ""},
+	{&options{NewName: "clone", ExportSuffix: ".b"}, "", ".b"},
+}
+
+func TestExportAffixes(t *testing.T) {
+	for _, tc := range exportAffixesTcs {
+		prefix, suffix := exportAffixes(tc.opts)
+		if prefix != tc.prefix || suffix != tc.suffix {
+			t.Fatalf("Expected '%v' '%v' got '%v' '%v'", tc.prefix, tc.suffix, prefix, suffix)
+		}
+	}
+}
+
+var renameExportsForTcs = []struct {
+	opts   *options
+	scope  rewriteScope
+	rename bool
+}{
+	{&options{}, rewriteScope{}, true},
+	{&options{}, rewriteScope{SourceRegion: "us-east-1", TargetRegion: "us-east-1", SourceAccount: "1", TargetAccount: "1"}, true},
+	{&options{}, rewriteScope{SourceRegion: "us-east-1", TargetRegion: "eu-west-1"}, false},
+	{&options{}, rewriteScope{SourceAccount: "1", TargetAccount: "2"}, false},
+	{&options{ExportSuffix: "-dr"}, rewriteScope{SourceRegion: "us-east-1", TargetRegion: "eu-west-1"}, true},
+	{&options{NoExportRename: true}, rewriteScope{}, false},
+}
+
+func TestRenameExportsFor(t *testing.T) {
+	for _, tc := range renameExportsForTcs {
+		if rename := renameExportsFor(tc.opts, tc.scope); rename != tc.rename {
+			t.Fatalf("Expected '%v' for '%v' '%v' got '%v'", tc.rename, tc.opts, tc.scope, rename)
+		}
+	}
+}
@@ -2,63 +2,453 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 )
 
-func prettyParameters(params map[string]string) string {
+func prettyValues(title string, values map[string]string) string {
 	var b bytes.Buffer
-	if len(params) > 0 {
+	if len(values) > 0 {
 		w := new(tabwriter.Writer)
 		w.Init(&b, 0, 8, 0, '\t', 0)
 
 		keys := []string{}
-		for k := range params {
+		for k := range values {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
 
-		b.Write([]byte("The merged parameters are:\n"))
+		b.Write([]byte(title + "\n"))
 		for _, k := range keys {
-			fmt.Fprintf(w, "%s \t%s\n", k, params[k])
+			fmt.Fprintf(w, "%s \t%s\n", k, values[k])
 		}
 		w.Flush()
 	}
 	return b.String()
 }
 
-func main() {
-	options := parseCliArgs()
+func prettyParameters(params map[string]string) string {
+	return prettyValues("The merged parameters are:", params)
+}
+
+func prettyTags(tags map[string]string) string {
+	return prettyValues("The merged tags are:", tags)
+}
+
+// mergeTags layers the tag overrides over the source stack's tags, dropping
+// removed keys, the reserved aws: prefixed ones and those cfn-clone recorded
+// when the source is itself a clone.
+func mergeTags(source map[string]string, overrides map[string]string, removed []string) map[string]string {
+	tags := map[string]string{}
+	for k, v := range source {
+		if !strings.HasPrefix(k, "aws:") && !strings.HasPrefix(k, cloneTagPrefix) {
+			tags[k] = v
+		}
+	}
 
-	newTemplate, err := newStackTemplateFile(options.SourceName, options.Template)
+	for _, k := range removed {
+		delete(tags, k)
+	}
+
+	for k, v := range overrides {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// prepareClone gathers the template and merged parameters for the new stack
+// and runs every validation, without changing anything. With a prompter,
+// missing and NoEcho values are asked for instead of failing. When syncing,
+// previous holds the parameters the clone overrode, which keep their values
+// unless overridden again.
+func prepareClone(client CloudFormationClient, options *options, previous map[string]string, redactor *redactor, in prompter, out io.Writer) (*StackInput, *plan, error) {
+	if err := validateSourceStackExists(client, options.SourceName); err != nil {
+		return nil, nil, err
+	}
+
+	newTemplate, err := template(client, options.SourceName, options.Template)
 	if err != nil {
-		fmt.Printf("Erroring getting the template for cloning. %s\n", err.Error())
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("Erroring getting the template for cloning. %s", err.Error())
 	}
-	defer os.Remove(newTemplate)
 
-	parameters, err := stackParameters(options.SourceName)
+	tmpl, err := parseTemplate(newTemplate)
 	if err != nil {
-		fmt.Printf("Error getting source stack parameters. %s\n", err.Error())
-		os.Exit(1)
+		fmt.Fprintf(out, "Warning: %s Parameters will not be checked against the template and NoEcho parameters will only be redacted by name.\n", err.Error())
+	} else {
+		redactor.addKeys(tmpl.noEchoParameters()...)
+	}
+
+	sourceTmpl := tmpl
+	if options.Template != "" {
+		sourceTmpl = nil
+		if body, err := stackTemplate(client, options.SourceName); err == nil {
+			sourceTmpl, _ = parseTemplate(body)
+		}
+	}
+	if sourceTmpl != nil {
+		redactor.addKeys(sourceTmpl.noEchoParameters()...)
+	}
+
+	source, err := client.DescribeStack(options.SourceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting source stack parameters. %s", err.Error())
+	}
+
+	parameters := map[string]string{}
+	for k, v := range source.Parameters {
+		parameters[k] = v
+	}
+
+	overridden := map[string]bool{}
+	kept := map[string]bool{}
+	for k, v := range previous {
+		parameters[k] = v
+		overridden[k] = true
+		kept[k] = true
+	}
+
+	fileTags := map[string]string{}
+	for _, path := range options.ParamsFiles {
+		f, err := readParametersFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range f.Parameters {
+			parameters[k] = v
+			overridden[k] = true
+			delete(kept, k)
+		}
+		for k, v := range f.Tags {
+			fileTags[k] = v
+		}
 	}
 
-	for k, v := range paramsFromCli(options.Attributes) {
+	overrides := paramsFromCli(options.Attributes)
+	references := parameterReferences(overrides)
+	for k, v := range overrides {
 		parameters[k] = v
+		overridden[k] = true
+		delete(kept, k)
+	}
+
+	defaults := map[string]string{}
+	if tmpl != nil {
+		keep := map[string]string{}
+		if options.AllowUnknown {
+			keep = overrides
+		} else if err = validateOverrideKeys(tmpl, overrides); err != nil {
+			return nil, nil, err
+		}
+
+		var r *reconciliation
+		parameters, r = reconcileParameters(tmpl, parameters, keep)
+
+		for _, k := range r.Dropped {
+			fmt.Fprintf(out, "Warning: dropping parameter '%s', which the template does not declare.\n", k)
+		}
+
+		if in != nil {
+			if err = promptForMissing(in, tmpl, parameters, r, redactor, out); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err = r.missingError(tmpl); err != nil {
+			return nil, nil, err
+		}
+
+		defaults = r.Defaults
+		if len(defaults) > 0 {
+			fmt.Fprintln(out, prettyValues("These parameters will use their template defaults:", redactor.parameters(defaults)))
+		}
+	}
+
+	masked := maskedParameters(sourceTmpl, parameters, overridden)
+	if in != nil {
+		if err = promptForNoEcho(in, tmpl, parameters, masked, redactor, out); err != nil {
+			return nil, nil, err
+		}
+	} else if err = validateNoEchoOverridden(masked, tmpl, sourceTmpl); err != nil {
+		return nil, nil, fmt.Errorf("Unable to create new stack. %s", err.Error())
+	}
+
+	ssm := []ssmParameter{}
+	if tmpl != nil {
+		ssm = ssmParameters(tmpl, parameters, source.ResolvedParameters, overridden)
+		if options.PinSSM {
+			if newTemplate, err = pinSSMParameters(newTemplate, tmpl, parameters, ssm); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else if options.PinSSM {
+		return nil, nil, errors.New("Unable to pin SSM parameters without a template that can be parsed.")
+	}
+
+	scope := newRewriteScope(options, source)
+
+	var exports []rewrite
+	if tmpl != nil && renameExportsFor(options, scope) {
+		prefix, suffix := exportAffixes(options)
+		renamed, r, err := renameExports(newTemplate, prefix, suffix)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: %s The template is used as written.\n", err.Error())
+		} else {
+			newTemplate, exports = renamed, r
+		}
+	}
+	if len(exports) > 0 {
+		var b bytes.Buffer
+		writeRewrites(&b, "The renamed exports are:", exports)
+		fmt.Fprintln(out, b.String())
+	}
+
+	var names []physicalName
+	if tmpl != nil {
+		rewritten, n, nameWarnings, err := rewritePhysicalNames(newTemplate, options.PhysicalNames, "-"+options.NewName, scope)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: %s The template is used as written.\n", err.Error())
+		} else {
+			newTemplate, names = rewritten, n
+		}
+		for _, w := range nameWarnings {
+			fmt.Fprintf(out, "Warning: %s.\n", w)
+		}
+	}
+	if len(names) > 0 {
+		var b bytes.Buffer
+		writePhysicalNames(&b, "The physical resource names are:", names)
+		fmt.Fprintln(out, b.String())
+	}
+	if n := physicalNameCollisions(names); n > 0 {
+		fmt.Fprintf(out, "Warning: %d physical names collide with the source stack's resources, set --physical-names to 'suffix' or 'remove' to change them.\n", n)
+	}
+
+	mapping := map[string]string{}
+	if options.RewriteMap != "" {
+		if mapping, err = readRewriteMap(options.RewriteMap); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if options.NoArnRewrite {
+		scope = rewriteScope{}
+	}
+
+	var rewrites []rewrite
+	var warnings []string
+	parameters, rewrites, warnings = rewriteParameters(parameters, overridden, scope, mapping)
+	for _, w := range warnings {
+		fmt.Fprintf(out, "Warning: %s.\n", w)
+	}
+
+	tracked := map[string]string{}
+	for k, v := range parameters {
+		if _, ok := references[k]; !ok {
+			tracked[k] = v
+		}
+	}
+	redactor.track(tracked)
+
+	if len(rewrites) > 0 {
+		fmt.Fprintln(out, prettyRewrites(redactor, rewrites))
+	}
+
+	fmt.Fprintln(out, prettyParameters(ssmDisplayValues(redactor, parameters, ssm)))
+
+	for k, v := range tagsFromCli(options.Tags) {
+		fileTags[k] = v
+	}
+	tags := mergeTags(source.Tags, fileTags, options.RemoveTags)
+	for k, v := range cloneTags(options.SourceName, parameters, overridden) {
+		tags[k] = v
+	}
+
+	fmt.Fprintln(out, prettyTags(tags))
+
+	if tmpl != nil {
+		if err = validateParameters(tmpl, parameters); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	capabilities := capabilitiesFromCli(options.Capabilities)
+	if len(capabilities) == 0 {
+		if tmpl != nil {
+			capabilities = requiredCapabilities(tmpl)
+		} else {
+			capabilities = []capability{{Name: capabilityIAM, Reason: "the template could not be inspected"}}
+		}
+	}
+
+	fmt.Fprintln(out, prettyValues("The required capabilities are:", capabilityReasons(capabilities)))
+
+	sourceSettings := source.StackSettings
+	if !options.NoStackPolicy && options.StackPolicyFile == "" {
+		if sourceSettings.StackPolicyBody, err = client.GetStackPolicy(options.SourceName); err != nil {
+			return nil, nil, fmt.Errorf("Error getting source stack policy. %s", err.Error())
+		}
+	}
+
+	settings, settingsRewrites, err := mergeSettings(sourceSettings, options, scope, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(settingsRewrites) > 0 {
+		var b bytes.Buffer
+		writeRewrites(&b, "The rewritten stack settings are:", settingsRewrites)
+		fmt.Fprintln(out, b.String())
+	}
+	rewrites = append(rewrites, settingsRewrites...)
+
+	if len(settingsValues(settings)) > 0 {
+		fmt.Fprintln(out, prettySettings(settings))
+	}
+
+	if err = validateStaging(newTemplate, options.TemplateBucket); err != nil {
+		return nil, nil, err
+	}
+
+	input := &StackInput{
+		StackName:          options.NewName,
+		TemplateBody:       newTemplate,
+		Parameters:         parameters,
+		PreviousParameters: keptParameters(parameters, kept),
+		Capabilities:       capabilityNames(capabilities),
+		Tags:               tags,
+		StackSettings:      settings,
+	}
+
+	plan := newPlan(options, source, input, redactor)
+	plan.Settings = diffValues(settingsValues(sourceSettings), settingsValues(settings))
+	plan.Defaults = redactor.parameters(defaults)
+	plan.Rewrites = redactRewrites(redactor, rewrites)
+	plan.SSMParameters = redactSSMParameters(redactor, ssm)
+	plan.References = references
+	plan.CapabilityReasons = capabilityReasons(capabilities)
+	plan.Exports = exports
+	plan.PhysicalNames = names
+	if needsStaging(newTemplate) {
+		plan.TemplateStaging = fmt.Sprintf("s3://%s/%s (%d bytes)", options.TemplateBucket, options.TemplatePrefix, len(newTemplate))
+	}
+	if options.PinSSM && len(ssm) > 0 {
+		plan.TemplateSource += " with SSM parameter types pinned"
+	}
+	if options.ChangeSet {
+		plan.CreatedWith = "change set, executed once approved"
+		if options.Approve {
+			plan.CreatedWith = "change set, approved with --approve"
+		}
+	}
+
+	return input, plan, nil
+}
+
+// clone reads the source stack with source and creates the new stack with
+// target, which are the same client unless cloning across regions or accounts.
+func clone(source CloudFormationClient, target CloudFormationClient, options *options, redactor *redactor, in prompter, out io.Writer) error {
+	if options.Sync {
+		return syncClone(source, target, options, redactor, in, out)
+	}
+
+	input, plan, err := prepareClone(source, options, nil, redactor, in, out)
+	if err != nil {
+		return err
+	}
+
+	if options.DryRun {
+		fmt.Fprintln(out, plan)
+		fmt.Fprintln(out, "Dry run, no stack was created.")
+		return nil
+	}
+
+	if len(plan.References) > 0 {
+		if err = resolveReferences(input, plan.References, target, redactor); err != nil {
+			return err
+		}
+	}
+
+	cleanup, err := stageTemplate(target, input, options, time.Now(), out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Going to clone")
+
+	var output string
+	if options.ChangeSet {
+		output, err = applyChangeSet(target, input, changeSetTypeCreate, options, in, time.Now(), out)
+	} else if output, err = target.CreateStack(input); err != nil {
+		err = fmt.Errorf("Unable to create new stack. %s", err.Error())
+	}
+	cleanup()
+	if err == errChangeSetRejected {
+		fmt.Fprintf(out, "%s, no stack was created.\n", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Success with output '%s'.\n", output)
+
+	if options.Wait {
+		fmt.Fprintln(out, "Waiting for stack to complete")
+		if err = waitForStack(target, output, nil, out); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Stack '%s' created.\n", options.NewName)
 	}
 
-	fmt.Println(prettyParameters(parameters))
+	return nil
+}
 
-	fmt.Println("Going to clone")
+// resolveReferences reads the values of referenced parameters through the
+// target's credentials and checks them against the template's constraints.
+func resolveReferences(input *StackInput, references map[string]string, target CloudFormationClient, redactor *redactor) error {
+	store, _ := target.(secretStore)
+	if err := resolveParameters(input.Parameters, references, store, redactor); err != nil {
+		return err
+	}
 
-	output, err := createStack(options.NewName, parameters, newTemplate)
+	tmpl, err := parseTemplate(input.TemplateBody)
 	if err != nil {
-		fmt.Printf("Unable to create new stack. %s\n", err.Error())
+		return nil
+	}
+
+	resolved := map[string]string{}
+	for k := range references {
+		resolved[k] = input.Parameters[k]
+	}
+	return validateParameters(tmpl, resolved)
+}
+
+func main() {
+	options := parseCliArgs()
+
+	redactor, err := newRedactor(options.RedactPattern)
+	if err != nil {
+		fmt.Printf("Invalid redact pattern. %s\n", err.Error())
+		os.Exit(1)
+	}
+	out := redactor.writer(os.Stdout)
+
+	source, target, err := newClients(options, out)
+	if err != nil {
+		fmt.Printf("Unable to set up the %s backend. %s\n", options.Backend, err.Error())
+		os.Exit(1)
+	}
+
+	if err := clone(source, target, options, redactor, stdinPrompter(os.Stdin, out), out); err != nil {
+		fmt.Fprintf(out, "%s\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Success with output '%s'.\n", output)
 	os.Exit(0)
 }
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -21,3 +27,748 @@ func TestPrettyParameters(t *testing.T) {
 		t.Fatalf("Expected '%v' got '%v'", expected, out)
 	}
 }
+
+func newCloneFixture() *fakeClient {
+	c := newFakeClient()
+	c.stacks["source"] = &Stack{
+		StackName:  "source",
+		Parameters: map[string]string{"foo": "bar", "baz": "qux"},
+	}
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}, "Resources": {}}`
+	return c
+}
+
+func TestClone(t *testing.T) {
+	c := newCloneFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"foo=override"}}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(c.created) != 1 {
+		t.Fatalf("Expected 1 stack to be created got %d", len(c.created))
+	}
+
+	created := c.created[0]
+	expected := map[string]string{"foo": "override", "baz": "qux"}
+	if created.StackName != "clone" || !reflect.DeepEqual(created.Parameters, expected) {
+		t.Fatalf("Expected clone with '%v' got '%v' with '%v'", expected, created.StackName, created.Parameters)
+	}
+
+	if created.TemplateBody != c.templates["source"] {
+		t.Fatalf("Expected template '%s' got '%s'", c.templates["source"], created.TemplateBody)
+	}
+}
+
+var cloneErrorTcs = []struct {
+	method string
+	opts   *options
+}{
+	{"DescribeStack", &options{SourceName: "source", NewName: "clone"}},
+	{"GetTemplate", &options{SourceName: "source", NewName: "clone"}},
+	{"CreateStack", &options{SourceName: "source", NewName: "clone"}},
+	{"", &options{SourceName: "missing", NewName: "clone"}},
+}
+
+func TestCloneErrors(t *testing.T) {
+	for _, tc := range cloneErrorTcs {
+		c := newCloneFixture()
+		if tc.method != "" {
+			c.errors[tc.method] = errors.New("boom")
+		}
+
+		var out bytes.Buffer
+		if err := clone(c, c, tc.opts, testRedactor(), nil, &out); err == nil {
+			t.Fatalf("Expected error when %s fails", tc.method)
+		}
+
+		if len(c.created) != 0 {
+			t.Fatalf("Expected no stack to be created when %s fails", tc.method)
+		}
+	}
+}
+
+func TestCloneNoEchoNotOverridden(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}, "password": {"Type": "String", "NoEcho": true}}}`
+	c.stacks["source"].Parameters["password"] = "****"
+	opts := &options{SourceName: "source", NewName: "clone"}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil {
+		t.Fatalf("Expected error for NoEcho parameter without override")
+	}
+
+	opts.Attributes = []string{"password=secret"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+}
+
+func TestCloneDryRun(t *testing.T) {
+	c := newCloneFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"foo=override"}, DryRun: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(c.created) != 0 {
+		t.Fatalf("Expected no stack to be created during a dry run")
+	}
+
+	expected := regexp.MustCompile(`~ foo\s+bar -> override\n`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("Expected plan to show '%v' got '%s'", expected, out.String())
+	}
+}
+
+func TestCloneRedactsNoEchoParameters(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"Secret": {"Type": "String", "NoEcho": true}, "foo": {"Type": "String"}, "baz": {"Type": "String"}}}`
+	c.stacks["source"].Parameters["Secret"] = "****"
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"Secret=hunter2"}, DryRun: true}
+
+	r := testRedactor()
+	var b bytes.Buffer
+	if err := clone(c, c, opts, r, nil, r.writer(&b)); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if strings.Contains(b.String(), "hunter2") {
+		t.Fatalf("Expected NoEcho value to be redacted got '%s'", b.String())
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	source := map[string]string{"Owner": "me", "CostCenter": "42", "Temp": "yes", "aws:cloudformation:stack-name": "source"}
+	overrides := map[string]string{"Owner": "you", "Env": "staging"}
+
+	expected := map[string]string{"Owner": "you", "CostCenter": "42", "Env": "staging"}
+
+	tags := mergeTags(source, overrides, []string{"Temp"})
+
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, tags)
+	}
+}
+
+func TestCloneCopiesTags(t *testing.T) {
+	c := newCloneFixture()
+	c.stacks["source"].Tags = map[string]string{"Owner": "me", "CostCenter": "42"}
+	opts := &options{SourceName: "source", NewName: "clone", Tags: []string{"Env=staging"}, RemoveTags: []string{"Owner"}}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{"CostCenter": "42", "Env": "staging", "cfn-clone:source": "source"}
+	if !reflect.DeepEqual(c.created[0].Tags, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].Tags)
+	}
+
+	if !strings.Contains(out.String(), "The merged tags are:") {
+		t.Fatalf("Expected merged tags in output got '%s'", out.String())
+	}
+}
+
+func TestCloneOfAClone(t *testing.T) {
+	c := newCloneFixture()
+	c.stacks["source"].Tags = map[string]string{"Owner": "me", sourceTag: "original", overridesTag: "foo", overridesTag + ":2": "baz"}
+	opts := &options{SourceName: "source", NewName: "clone"}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{"Owner": "me", sourceTag: "source"}
+	if !reflect.DeepEqual(c.created[0].Tags, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].Tags)
+	}
+}
+
+func TestCloneParametersFileLayering(t *testing.T) {
+	f, err := ioutil.TempFile("", "cfn-clone-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp file for testing parameter files")
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	f.WriteString(`{"Parameters": {"foo": "from-file", "baz": "from-file"}, "Tags": {"Owner": "file"}}`)
+	f.Sync()
+
+	c := newCloneFixture()
+	opts := &options{
+		SourceName:  "source",
+		NewName:     "clone",
+		ParamsFiles: []string{f.Name()},
+		Attributes:  []string{"foo=from-cli"},
+	}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{"foo": "from-cli", "baz": "from-file"}
+	if !reflect.DeepEqual(c.created[0].Parameters, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].Parameters)
+	}
+
+	if c.created[0].Tags["Owner"] != "file" {
+		t.Fatalf("Expected tag from file got '%v'", c.created[0].Tags)
+	}
+}
+
+func TestCloneValidatesConstraints(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String", "AllowedValues": ["bar", "other"]}, "baz": {"Type": "String"}}}`
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"foo=typo"}}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil {
+		t.Fatalf("Expected constraint violation for 'foo=typo'")
+	}
+
+	if len(c.created) != 0 {
+		t.Fatalf("Expected no stack to be created")
+	}
+}
+
+func TestCloneReconcilesOverriddenTemplate(t *testing.T) {
+	f, err := ioutil.TempFile("", "cfn-clone-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp file for testing template overrides")
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	f.WriteString(`{"Parameters": {"foo": {"Type": "String"}, "new": {"Type": "String", "Default": "x"}, "required": {"Type": "String"}}}`)
+	f.Sync()
+
+	c := newCloneFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Template: f.Name()}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil || !strings.Contains(err.Error(), "required") {
+		t.Fatalf("Expected missing required parameter error got '%v'", err)
+	}
+
+	opts.Attributes = []string{"required=y"}
+	out.Reset()
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{"foo": "bar", "required": "y"}
+	if !reflect.DeepEqual(c.created[0].Parameters, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].Parameters)
+	}
+
+	for _, s := range []string{"dropping parameter 'baz'", "These parameters will use their template defaults:\nnew"} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("Expected '%s' in '%s'", s, out.String())
+		}
+	}
+}
+
+func TestCloneRejectsUnknownOverrideKeys(t *testing.T) {
+	c := newCloneFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"fooo=typo"}}
+
+	var out bytes.Buffer
+	err := clone(c, c, opts, testRedactor(), nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "did you mean 'foo'?") {
+		t.Fatalf("Expected typo suggestion got '%v'", err)
+	}
+
+	opts.AllowUnknown = true
+	if err = clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if c.created[0].Parameters["fooo"] != "typo" {
+		t.Fatalf("Expected unknown parameter to be sent got '%v'", c.created[0].Parameters)
+	}
+}
+
+func TestCloneAcrossRegions(t *testing.T) {
+	source := newCloneFixture()
+	target := newFakeClient()
+	opts := &options{SourceName: "source", NewName: "clone", SourceRegion: "us-east-1", TargetRegion: "us-west-2"}
+
+	var out bytes.Buffer
+	if err := clone(source, target, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(source.created) != 0 || len(target.created) != 1 {
+		t.Fatalf("Expected stack to be created by the target client only")
+	}
+
+	if !reflect.DeepEqual(target.created[0].Parameters, source.stacks["source"].Parameters) {
+		t.Fatalf("Expected source parameters got '%v'", target.created[0].Parameters)
+	}
+}
+
+func TestCloneRewritesArns(t *testing.T) {
+	source := newFakeClient()
+	source.stacks["source"] = &Stack{
+		StackId:   "arn:aws:cloudformation:us-east-1:111111111111:stack/source/abc",
+		StackName: "source",
+		Parameters: map[string]string{
+			"Topic":   "arn:aws:sns:us-east-1:111111111111:alerts",
+			"Image":   "ami-0123456789abcdef0",
+			"Subnets": "subnet-0123456789abcdef0,subnet-11111111",
+			"Role":    "arn:aws:iam::111111111111:role/app",
+		},
+		StackSettings: StackSettings{RoleARN: "arn:aws:iam::111111111111:role/cfn"},
+	}
+	source.templates["source"] = `{"Parameters": {"Topic": {"Type": "String"}, "Image": {"Type": "String"}, "Subnets": {"Type": "CommaDelimitedList"}, "Role": {"Type": "String"}}}`
+	target := newFakeClient()
+
+	mapFile, err := ioutil.TempFile("", "cfn-clone-rewrite-map")
+	if err != nil {
+		t.Fatalf("Unable to create rewrite map. %s", err)
+	}
+	defer os.Remove(mapFile.Name())
+	mapFile.WriteString(`{"ami-0123456789abcdef0": "ami-0fedcba9876543210", "subnet-11111111": "subnet-22222222"}`)
+	mapFile.Close()
+
+	opts := &options{
+		SourceName:    "source",
+		NewName:       "clone",
+		SourceRegion:  "us-east-1",
+		TargetRegion:  "eu-west-1",
+		TargetRoleARN: "arn:aws:iam::222222222222:role/deploy",
+		RewriteMap:    mapFile.Name(),
+		DryRun:        true,
+	}
+
+	var out bytes.Buffer
+	if err := clone(source, target, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	for _, expected := range []string{
+		"arn:aws:sns:us-east-1:111111111111:alerts -> arn:aws:sns:eu-west-1:222222222222:alerts (arn region and account)",
+		"arn:aws:iam::111111111111:role/app -> arn:aws:iam::222222222222:role/app",
+		"(arn account)",
+		"Image   ami-0123456789abcdef0 -> ami-0fedcba9876543210",
+		"Warning: parameter 'Subnets' value 'subnet-0123456789abcdef0' is specific to the source region and account",
+		"Values rewritten for the target:",
+		"Settings.RoleARN arn:aws:iam::111111111111:role/cfn -> arn:aws:iam::222222222222:role/cfn",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected '%v' got '%v'", expected, out.String())
+		}
+	}
+
+	opts.DryRun = false
+	opts.Attributes = []string{"Topic=arn:aws:sns:us-east-1:111111111111:alerts"}
+	if err := clone(source, target, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{
+		"Topic":   "arn:aws:sns:us-east-1:111111111111:alerts",
+		"Image":   "ami-0fedcba9876543210",
+		"Subnets": "subnet-0123456789abcdef0,subnet-22222222",
+		"Role":    "arn:aws:iam::222222222222:role/app",
+	}
+	if !reflect.DeepEqual(target.created[0].Parameters, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, target.created[0].Parameters)
+	}
+}
+
+func TestCloneSSMParameters(t *testing.T) {
+	c := newFakeClient()
+	c.stacks["source"] = &Stack{
+		StackName:          "source",
+		Parameters:         map[string]string{"Image": "/app/ami", "Subnets": "/app/subnets", "Name": "app"},
+		ResolvedParameters: map[string]string{"Image": "ami-1", "Subnets": "subnet-1"},
+	}
+	c.templates["source"] = ssmTemplate
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if c.created[0].Parameters["Image"] != "/app/ami" || c.created[0].TemplateBody != ssmTemplate {
+		t.Fatalf("Expected the SSM name to be kept got '%v'", c.created[0].Parameters)
+	}
+
+	if !strings.Contains(out.String(), "/app/ami (resolves to 'ami-1')") {
+		t.Fatalf("Expected the resolved value to be listed got '%v'", out.String())
+	}
+
+	out.Reset()
+	opts.PinSSM = true
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	created := c.created[1]
+	if created.Parameters["Image"] != "ami-1" || !strings.Contains(created.TemplateBody, `"Type": "AWS::EC2::Image::Id"`) {
+		t.Fatalf("Expected the resolved value to be pinned got '%v' '%v'", created.Parameters, created.TemplateBody)
+	}
+
+	if !strings.Contains(out.String(), "ami-1 (pinned from '/app/ami')") {
+		t.Fatalf("Expected the pinned value to be listed got '%v'", out.String())
+	}
+}
+
+func TestCloneResolvesReferences(t *testing.T) {
+	source := newCloneFixture()
+	target := newFakeClient()
+	target.ssm["/app/foo"] = "resolved-foo"
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"foo=ssm:/app/foo"}, DryRun: true}
+	if err := clone(source, target, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if strings.Contains(out.String(), "resolved-foo") || !strings.Contains(out.String(), "Parameters resolved before creation:") {
+		t.Fatalf("Expected the reference to be listed without resolving it got '%v'", out.String())
+	}
+
+	out.Reset()
+	opts.DryRun = false
+	r := testRedactor()
+	if err := clone(source, target, opts, r, nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if target.created[0].Parameters["foo"] != "resolved-foo" {
+		t.Fatalf("Expected 'resolved-foo' got '%v'", target.created[0].Parameters["foo"])
+	}
+
+	if r.value("foo", "resolved-foo") != redactedValue {
+		t.Fatalf("Expected resolved parameters to be secret")
+	}
+
+	opts.Attributes = []string{"foo=ssm:/app/missing"}
+	if err := clone(source, target, opts, testRedactor(), nil, &out); err == nil || len(target.created) != 1 {
+		t.Fatalf("Expected an unresolvable reference to fail before creating got '%v'", err)
+	}
+}
+
+func TestClonePromptsForNoEchoAndMissing(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}, "password": {"Type": "String", "NoEcho": true, "Description": "Database password"}, "owner": {"Type": "String"}}}`
+	c.stacks["source"].Parameters["password"] = "****"
+	opts := &options{SourceName: "source", NewName: "clone"}
+
+	var out bytes.Buffer
+	p := &scriptedPrompter{answers: []string{"me", "hunter2", "hunter2"}}
+	r := testRedactor()
+	if err := clone(c, c, opts, r, p, r.writer(&out)); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := []string{"owner", "hidden password", "hidden Confirm password"}
+	if !reflect.DeepEqual(p.asked, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, p.asked)
+	}
+
+	created := c.created[0]
+	if created.Parameters["owner"] != "me" || created.Parameters["password"] != "hunter2" {
+		t.Fatalf("Expected prompted values got '%v'", created.Parameters)
+	}
+
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), "Database password") {
+		t.Fatalf("Expected the prompt to be described and the value hidden got '%v'", out.String())
+	}
+}
+
+func TestCloneDetectsNoEchoFromSourceTemplate(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String", "NoEcho": true, "Description": "The baz secret"}}}`
+	c.stacks["source"].Parameters["foo"] = "****"
+
+	tmpl, err := ioutil.TempFile("", "cfn-clone-template")
+	if err != nil {
+		t.Fatalf("Unable to create template. %s", err)
+	}
+	defer os.Remove(tmpl.Name())
+	tmpl.WriteString(`{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}}`)
+	tmpl.Close()
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", Template: tmpl.Name()}
+	err = clone(c, c, opts, testRedactor(), nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "  baz: The baz secret") || strings.Contains(err.Error(), "foo") {
+		t.Fatalf("Expected only baz to be reported got '%v'", err)
+	}
+
+	opts.Attributes = []string{"baz=new"}
+	if err = clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if c.created[0].Parameters["foo"] != "****" {
+		t.Fatalf("Expected a real value of '****' to be cloned got '%v'", c.created[0].Parameters["foo"])
+	}
+}
+
+func TestCloneDetectsCapabilities(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}, "Resources": {"Role": {"Type": "AWS::IAM::Role", "Properties": {"RoleName": "app"}}}}`
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", DryRun: true}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !strings.Contains(out.String(), "CAPABILITY_NAMED_IAM: resource 'Role' sets RoleName") {
+		t.Fatalf("Expected the capability to be explained got '%v'", out.String())
+	}
+
+	opts.DryRun = false
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := []string{capabilityIAM, capabilityNamedIAM}
+	if !reflect.DeepEqual(c.created[0].Capabilities, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].Capabilities)
+	}
+
+	opts.Capabilities = []string{"CAPABILITY_AUTO_EXPAND"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected = []string{capabilityAutoExpand}
+	if !reflect.DeepEqual(c.created[1].Capabilities, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[1].Capabilities)
+	}
+}
+
+func TestCloneYAMLTemplate(t *testing.T) {
+	c := newFakeClient()
+	c.stacks["source"] = &Stack{
+		StackName:          "source",
+		Parameters:         map[string]string{"Image": "/app/ami", "Password": "****"},
+		ResolvedParameters: map[string]string{"Image": "ami-1"},
+	}
+	c.templates["source"] = yamlTemplate
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", Attributes: []string{"Password=hunter2!"}}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	created := c.created[0]
+	if created.TemplateBody != yamlTemplate {
+		t.Fatalf("Expected the template to be passed as written got '%v'", created.TemplateBody)
+	}
+
+	expected := []string{capabilityIAM, capabilityAutoExpand}
+	if !reflect.DeepEqual(created.Capabilities, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, created.Capabilities)
+	}
+
+	opts.PinSSM = true
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if body := c.created[1].TemplateBody; !strings.Contains(body, "Type: AWS::EC2::Image::Id") || !strings.Contains(body, "ImageId: !Ref Image") {
+		t.Fatalf("Expected a pinned YAML template got '%v'", body)
+	}
+}
+
+func TestCloneStagesLargeTemplates(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Description": "` + strings.Repeat("x", inlineTemplateLimit) + `", "Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}}`
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil || !strings.Contains(err.Error(), "--template-bucket") {
+		t.Fatalf("Expected an error asking for --template-bucket got '%v'", err)
+	}
+
+	opts.TemplateBucket = "bucket"
+	opts.DryRun = true
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if !strings.Contains(out.String(), "Template staged in: s3://bucket/") || len(c.objects) != 0 {
+		t.Fatalf("Expected the plan to show the staging got '%v'", out.String())
+	}
+
+	opts.DryRun = false
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !strings.HasPrefix(c.created[0].TemplateURL, "https://bucket.s3.amazonaws.com/clone-") || len(c.objects) != 0 {
+		t.Fatalf("Expected a staged and cleaned up template got '%v' '%v'", c.created[0].TemplateURL, c.objects)
+	}
+}
+
+func TestCloneCopiesStackSettings(t *testing.T) {
+	c := newCloneFixture()
+	c.stacks["source"].StackSettings = StackSettings{
+		NotificationARNs:            []string{"arn:aws:sns:us-east-1:123456789012:events"},
+		RoleARN:                     "arn:aws:iam::123456789012:role/cfn",
+		TimeoutInMinutes:            30,
+		EnableTerminationProtection: true,
+	}
+	c.policies["source"] = `{"Statement": []}`
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", NoStackRole: true, TimeoutInMinutes: 60}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := StackSettings{
+		NotificationARNs:            []string{"arn:aws:sns:us-east-1:123456789012:events"},
+		StackPolicyBody:             `{"Statement": []}`,
+		TimeoutInMinutes:            60,
+		EnableTerminationProtection: true,
+	}
+	if !reflect.DeepEqual(c.created[0].StackSettings, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, c.created[0].StackSettings)
+	}
+
+	opts.DryRun = true
+	out.Reset()
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	for _, line := range []string{`- RoleARN\s+arn:aws:iam::123456789012:role/cfn\n`, `~ TimeoutInMinutes\s+30 -> 60\n`, `= TerminationProtection\s+enabled\n`} {
+		if !regexp.MustCompile(line).MatchString(out.String()) {
+			t.Fatalf("Expected plan to show '%s' got '%s'", line, out.String())
+		}
+	}
+
+	c.errors["GetStackPolicy"] = errors.New("boom")
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil {
+		t.Fatalf("Expected an error when the stack policy can not be read")
+	}
+}
+
+func TestCloneWithChangeSet(t *testing.T) {
+	c := newCloneFixture()
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", ChangeSet: true}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil || !strings.Contains(err.Error(), "--approve") {
+		t.Fatalf("Expected an error asking for --approve got '%v'", err)
+	}
+	if len(c.executed) != 0 || len(c.deletedChangeSet) != 1 {
+		t.Fatalf("Expected the unapproved change set to be deleted got '%v' '%v'", c.executed, c.deletedChangeSet)
+	}
+
+	in := &scriptedPrompter{answers: []string{"n"}}
+	if err := clone(c, c, opts, testRedactor(), in, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if len(c.executed) != 0 || !strings.Contains(out.String(), "no stack was created") {
+		t.Fatalf("Expected the rejected change set not to be executed got '%v'", c.executed)
+	}
+
+	opts.Approve = true
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if len(c.created) != 0 || len(c.executed) != 1 || c.createdChangeSet[2].Parameters["foo"] != "bar" {
+		t.Fatalf("Expected the clone to be created through a change set got '%v' '%v'", c.created, c.executed)
+	}
+}
+
+func TestCloneRenamesExports(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}, "Resources": {}, "Outputs": {"Vpc": {"Value": "x", "Export": {"Name": "vpc-id"}}}}`
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", DryRun: true}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := regexp.MustCompile(`Exports renamed for the clone:\n  Vpc\s+vpc-id -> vpc-id-clone\s+\(literal\)\n`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("Expected plan to show '%v' got '%s'", expected, out.String())
+	}
+
+	opts.DryRun = false
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if !strings.Contains(c.created[0].TemplateBody, `"Name": "vpc-id-clone"`) {
+		t.Fatalf("Expected the export to be renamed got '%s'", c.created[0].TemplateBody)
+	}
+
+	opts.NoExportRename = true
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if c.created[1].TemplateBody != c.templates["source"] {
+		t.Fatalf("Expected the template as written got '%s'", c.created[1].TemplateBody)
+	}
+
+	c.stacks["source"].StackId = "arn:aws:cloudformation:us-east-1:111111111111:stack/source/abc"
+	opts = &options{SourceName: "source", NewName: "clone", TargetRegion: "eu-west-1"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if c.created[2].TemplateBody != c.templates["source"] {
+		t.Fatalf("Expected exports to keep their names in another region got '%s'", c.created[2].TemplateBody)
+	}
+
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}, "Resources": {}, "Outputs": {"Vpc": {"Value": "a\/b", "Export": {"Name": "vpc-id"}}}}`
+	c.stacks["source"].StackId = "arn:aws:cloudformation:us-east-1:123456789012:stack/source/abc"
+	opts = &options{SourceName: "source", NewName: "clone"}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if !strings.Contains(c.created[3].TemplateBody, `"Name": "vpc-id-clone"`) {
+		t.Fatalf("Expected the export of a template with escapes to be renamed got '%s'", c.created[3].TemplateBody)
+	}
+}
+
+func TestCloneRewritesPhysicalNames(t *testing.T) {
+	c := newCloneFixture()
+	c.templates["source"] = `{"Parameters": {"foo": {"Type": "String"}, "baz": {"Type": "String"}}, "Resources": {"Bucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": "assets"}}}}`
+
+	var out bytes.Buffer
+	opts := &options{SourceName: "source", NewName: "clone", DryRun: true, PhysicalNames: physicalNamesKeep}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !strings.Contains(out.String(), "Warning: 1 physical names collide with the source stack's resources") {
+		t.Fatalf("Expected a warning about the collision got '%s'", out.String())
+	}
+	expected := regexp.MustCompile(`Physical resource names:\n  Bucket\s+AWS::S3::Bucket.BucketName\s+assets\s+\(collides\)\n`)
+	if !expected.MatchString(out.String()) {
+		t.Fatalf("Expected plan to show '%v' got '%s'", expected, out.String())
+	}
+
+	opts.DryRun = false
+	opts.PhysicalNames = physicalNamesSuffix
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if !strings.Contains(c.created[0].TemplateBody, `"BucketName": "assets-clone"`) {
+		t.Fatalf("Expected the bucket name to be suffixed got '%s'", c.created[0].TemplateBody)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	physicalNamesKeep   = "keep"
+	physicalNamesSuffix = "suffix"
+	physicalNamesRemove = "remove"
+)
+
+// Where a physical name has to be unique.
+const (
+	nameScopeGlobal  = "global"
+	nameScopeAccount = "account"
+	nameScopeRegion  = "region"
+)
+
+// nameProperty is the property setting the physical name of a resource type.
+// Fifo is the property marking FIFO resources, whose names end in ".fifo".
+type nameProperty struct {
+	Property  string
+	Scope     string
+	MaxLength int
+	Lowercase bool
+	Fifo      string
+}
+
+// nameProperties are the resource properties known to set a unique physical
+// name. Each of them is optional, so CloudFormation can generate the name
+// instead.
+var nameProperties = map[string]nameProperty{
+	"AWS::CloudWatch::Alarm":                    {Property: "AlarmName", Scope: nameScopeRegion, MaxLength: 255},
+	"AWS::DynamoDB::Table":                      {Property: "TableName", Scope: nameScopeRegion, MaxLength: 255},
+	"AWS::ECR::Repository":                      {Property: "RepositoryName", Scope: nameScopeRegion, MaxLength: 256, Lowercase: true},
+	"AWS::ECS::Cluster":                         {Property: "ClusterName", Scope: nameScopeRegion, MaxLength: 255},
+	"AWS::ElastiCache::ReplicationGroup":        {Property: "ReplicationGroupId", Scope: nameScopeRegion, MaxLength: 40, Lowercase: true},
+	"AWS::ElasticLoadBalancingV2::LoadBalancer": {Property: "Name", Scope: nameScopeRegion, MaxLength: 32},
+	"AWS::ElasticLoadBalancingV2::TargetGroup":  {Property: "Name", Scope: nameScopeRegion, MaxLength: 32},
+	"AWS::Events::Rule":                         {Property: "Name", Scope: nameScopeRegion, MaxLength: 64},
+	"AWS::IAM::Group":                           {Property: "GroupName", Scope: nameScopeAccount, MaxLength: 128},
+	"AWS::IAM::InstanceProfile":                 {Property: "InstanceProfileName", Scope: nameScopeAccount, MaxLength: 128},
+	"AWS::IAM::ManagedPolicy":                   {Property: "ManagedPolicyName", Scope: nameScopeAccount, MaxLength: 128},
+	"AWS::IAM::Role":                            {Property: "RoleName", Scope: nameScopeAccount, MaxLength: 64},
+	"AWS::IAM::User":                            {Property: "UserName", Scope: nameScopeAccount, MaxLength: 64},
+	"AWS::Kinesis::Stream":                      {Property: "Name", Scope: nameScopeRegion, MaxLength: 128},
+	"AWS::Lambda::Function":                     {Property: "FunctionName", Scope: nameScopeRegion, MaxLength: 64},
+	"AWS::Logs::LogGroup":                       {Property: "LogGroupName", Scope: nameScopeRegion, MaxLength: 512},
+	"AWS::RDS::DBCluster":                       {Property: "DBClusterIdentifier", Scope: nameScopeRegion, MaxLength: 63, Lowercase: true},
+	"AWS::RDS::DBInstance":                      {Property: "DBInstanceIdentifier", Scope: nameScopeRegion, MaxLength: 63, Lowercase: true},
+	"AWS::S3::Bucket":                           {Property: "BucketName", Scope: nameScopeGlobal, MaxLength: 63, Lowercase: true},
+	"AWS::SNS::Topic":                           {Property: "TopicName", Scope: nameScopeRegion, MaxLength: 256, Fifo: "FifoTopic"},
+	"AWS::SQS::Queue":                           {Property: "QueueName", Scope: nameScopeRegion, MaxLength: 80, Fifo: "FifoQueue"},
+	"AWS::SSM::Parameter":                       {Property: "Name", Scope: nameScopeRegion, MaxLength: 2048},
+	"AWS::SecretsManager::Secret":               {Property: "Name", Scope: nameScopeRegion, MaxLength: 512},
+	"AWS::StepFunctions::StateMachine":          {Property: "StateMachineName", Scope: nameScopeRegion, MaxLength: 80},
+}
+
+// physicalName is a hard-coded physical name found in the template, and
+// what the clone does with it.
+type physicalName struct {
+	LogicalID string
+	Type      string
+	Property  string
+	From      string
+	To        string
+	Action    string
+}
+
+// collides reports whether a name unique within scope clashes with the
+// source stack's resource when cloned across s.
+func (p nameProperty) collides(s rewriteScope) bool {
+	switch p.Scope {
+	case nameScopeAccount:
+		return !s.crossesAccount()
+	case nameScopeRegion:
+		return !s.crossesAccount() && !s.crossesRegion()
+	}
+	return true
+}
+
+// rewritePhysicalNames finds every resource in the template with a
+// hard-coded physical name, and for those that would collide with the source
+// stack's resources adds suffix to the name or removes it so CloudFormation
+// generates one, depending on mode. Names built from AWS::StackName are
+// already unique to the stack and are left alone. The body is returned as
+// written when nothing is changed, which is always the case in keep mode,
+// along with warnings about names that became too long.
+func rewritePhysicalNames(body string, mode string, suffix string, scope rewriteScope) (string, []physicalName, []string, error) {
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Unable to inspect physical names. %s", err.Error())
+	}
+
+	resources := mappingValue(b.root(), "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return body, nil, nil, nil
+	}
+
+	keys := []string{}
+	nodes := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		keys = append(keys, resources.Content[i].Value)
+		nodes[resources.Content[i].Value] = resources.Content[i+1]
+	}
+	sort.Strings(keys)
+
+	names := []physicalName{}
+	warnings := []string{}
+	changed := false
+	for _, k := range keys {
+		t := mappingValue(nodes[k], "Type")
+		if t == nil {
+			continue
+		}
+		prop, ok := nameProperties[t.Value]
+		if !ok {
+			continue
+		}
+		props := mappingValue(nodes[k], "Properties")
+		n := mappingValue(props, prop.Property)
+		if n == nil {
+			continue
+		}
+
+		name := physicalName{LogicalID: k, Type: t.Value, Property: prop.Property, From: nameString(n)}
+		switch {
+		case referencesStackName(n):
+			name.Action = "unique to the stack"
+		case !prop.collides(scope):
+			name.Action = "unique to the target " + prop.Scope
+		case mode == physicalNamesRemove:
+			removeMappingKey(props, prop.Property)
+			name.To = "(generated)"
+			name.Action = "removed"
+			changed = true
+		case mode == physicalNamesSuffix:
+			s := suffix
+			if prop.Lowercase {
+				s = strings.ToLower(s)
+			}
+			if !suffixName(n, s, prop.Fifo != "" && mappingValue(props, prop.Fifo) != nil) {
+				name.Action = "collides, a FIFO name is only suffixed as a literal or Fn::Sub"
+				break
+			}
+			name.To = nameString(n)
+			name.Action = "suffixed"
+			changed = true
+			if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && len(n.Value) > prop.MaxLength {
+				warnings = append(warnings, fmt.Sprintf("the %s '%s' of %s is longer than the %d characters %s allows", prop.Property, n.Value, k, prop.MaxLength, t.Value))
+			}
+		default:
+			name.Action = "collides"
+		}
+		names = append(names, name)
+	}
+
+	if !changed || mode == physicalNamesKeep {
+		return body, names, warnings, nil
+	}
+
+	rewritten, err := b.String()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Unable to rewrite physical names. %s", err.Error())
+	}
+
+	return rewritten, names, warnings, nil
+}
+
+// suffixName adds suffix to the name n in place. FIFO names keep ending in
+// ".fifo", so they are only suffixed when they are a literal or Fn::Sub.
+func suffixName(n *yaml.Node, suffix string, fifo bool) bool {
+	if !fifo {
+		affixName(n, "", suffix)
+		return true
+	}
+
+	fn, isFunction := shortFormFunction(n.Tag)
+	switch {
+	case n.Kind == yaml.ScalarNode && !isFunction:
+	case fn == "Fn::Sub":
+		if n.Kind == yaml.SequenceNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+	case !isFunction && n.Kind == yaml.MappingNode && len(n.Content) == 2 && n.Content[0].Value == "Fn::Sub":
+		n = n.Content[1]
+		if n.Kind == yaml.SequenceNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+	default:
+		return false
+	}
+
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	if strings.HasSuffix(n.Value, ".fifo") {
+		n.Value = strings.TrimSuffix(n.Value, ".fifo") + suffix + ".fifo"
+	} else {
+		n.Value += suffix
+	}
+	return true
+}
+
+// removeMappingKey deletes key and its value from the map n.
+func removeMappingKey(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// physicalNameCollisions counts the names that clash with the source stack's
+// resources and are not being changed.
+func physicalNameCollisions(names []physicalName) int {
+	count := 0
+	for _, n := range names {
+		if strings.HasPrefix(n.Action, "collides") {
+			count++
+		}
+	}
+	return count
+}
+
+func writePhysicalNames(b *bytes.Buffer, title string, names []physicalName) {
+	if len(names) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(b, 0, 8, 1, ' ', 0)
+
+	b.WriteString(title + "\n")
+	for _, n := range names {
+		name := n.From
+		if n.To != "" {
+			name += " -> " + n.To
+		}
+		fmt.Fprintf(w, "  %s\t%s.%s\t%s\t(%s)\n", n.LogicalID, n.Type, n.Property, name, n.Action)
+	}
+	w.Flush()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const namesTemplate = `{
+  "Resources": {
+    "Bucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": "assets"}},
+    "Jobs": {"Type": "AWS::SQS::Queue", "Properties": {"QueueName": "jobs.fifo", "FifoQueue": true}},
+    "Role": {"Type": "AWS::IAM::Role", "Properties": {"RoleName": {"Fn::Sub": "${Env}-worker"}}},
+    "Table": {"Type": "AWS::DynamoDB::Table", "Properties": {"TableName": {"Ref": "TableName"}}},
+    "Logs": {"Type": "AWS::Logs::LogGroup", "Properties": {"LogGroupName": {"Fn::Sub": "/app/${AWS::StackName}"}}},
+    "Topic": {"Type": "AWS::SNS::Topic", "Properties": {}}
+  }
+}`
+
+// propertiesOf returns the properties of every resource in the template.
+func propertiesOf(t *testing.T, body string) map[string]interface{} {
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		t.Fatalf("Unable to parse '%s': %v", body, err)
+	}
+	v, _ := b.value()
+
+	props := map[string]interface{}{}
+	for k, r := range v.(map[string]interface{})["Resources"].(map[string]interface{}) {
+		props[k] = r.(map[string]interface{})["Properties"]
+	}
+	return props
+}
+
+var sameRegion = rewriteScope{SourceRegion: "us-east-1", TargetRegion: "us-east-1", SourceAccount: "1", TargetAccount: "1"}
+
+func TestRewritePhysicalNamesKeep(t *testing.T) {
+	body, names, _, err := rewritePhysicalNames(namesTemplate, physicalNamesKeep, "-Clone", sameRegion)
+	if err != nil || body != namesTemplate {
+		t.Fatalf("Expected the template as written got '%s' with '%v'", body, err)
+	}
+
+	expected := []physicalName{
+		{LogicalID: "Bucket", Type: "AWS::S3::Bucket", Property: "BucketName", From: "assets", Action: "collides"},
+		{LogicalID: "Jobs", Type: "AWS::SQS::Queue", Property: "QueueName", From: "jobs.fifo", Action: "collides"},
+		{LogicalID: "Logs", Type: "AWS::Logs::LogGroup", Property: "LogGroupName", From: `{"Fn::Sub":"/app/${AWS::StackName}"}`, Action: "unique to the stack"},
+		{LogicalID: "Role", Type: "AWS::IAM::Role", Property: "RoleName", From: `{"Fn::Sub":"${Env}-worker"}`, Action: "collides"},
+		{LogicalID: "Table", Type: "AWS::DynamoDB::Table", Property: "TableName", From: `{"Ref":"TableName"}`, Action: "collides"},
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, names)
+	}
+
+	if physicalNameCollisions(names) != 4 {
+		t.Fatalf("Expected 4 collisions got %d", physicalNameCollisions(names))
+	}
+}
+
+func TestRewritePhysicalNamesJSONEscapes(t *testing.T) {
+	template := `{"Resources": {"Logs": {"Type": "AWS::Logs::LogGroup", "Properties": {"LogGroupName": "\/app\/logs"}}}}`
+	body, names, _, err := rewritePhysicalNames(template, physicalNamesKeep, "-clone", sameRegion)
+	if err != nil || body != template {
+		t.Fatalf("Expected the template as written got '%s' with '%v'", body, err)
+	}
+	if len(names) != 1 || names[0].From != "/app/logs" {
+		t.Fatalf("Expected the escaped name to be reported got '%v'", names)
+	}
+
+	body, _, _, err = rewritePhysicalNames(template, physicalNamesSuffix, "-clone", sameRegion)
+	if err != nil || !strings.Contains(body, `"LogGroupName": "/app/logs-clone"`) {
+		t.Fatalf("Expected the escaped name to be suffixed got '%s' with '%v'", body, err)
+	}
+}
+
+func TestRewritePhysicalNamesSuffix(t *testing.T) {
+	body, names, warnings, err := rewritePhysicalNames(namesTemplate, physicalNamesSuffix, "-Clone", sameRegion)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Expected no error got '%v' '%v'", err, warnings)
+	}
+
+	props := propertiesOf(t, body)
+	expected := map[string]interface{}{
+		"Bucket": map[string]interface{}{"BucketName": "assets-clone"},
+		"Jobs":   map[string]interface{}{"QueueName": "jobs-Clone.fifo", "FifoQueue": true},
+		"Role":   map[string]interface{}{"RoleName": map[string]interface{}{"Fn::Sub": "${Env}-worker-Clone"}},
+		"Table": map[string]interface{}{"TableName": map[string]interface{}{"Fn::Join": []interface{}{"", []interface{}{
+			map[string]interface{}{"Ref": "TableName"}, "-Clone",
+		}}}},
+		"Logs":  map[string]interface{}{"LogGroupName": map[string]interface{}{"Fn::Sub": "/app/${AWS::StackName}"}},
+		"Topic": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(props, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, props)
+	}
+
+	if names[0].To != "assets-clone" || names[0].Action != "suffixed" || physicalNameCollisions(names) != 0 {
+		t.Fatalf("Expected every colliding name to be suffixed got '%v'", names)
+	}
+}
+
+func TestRewritePhysicalNamesRemove(t *testing.T) {
+	body, names, _, err := rewritePhysicalNames(namesTemplate, physicalNamesRemove, "-clone", sameRegion)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	props := propertiesOf(t, body)
+	if !reflect.DeepEqual(props["Bucket"], map[string]interface{}{}) || !reflect.DeepEqual(props["Jobs"], map[string]interface{}{"FifoQueue": true}) {
+		t.Fatalf("Expected the names to be removed got '%v'", props)
+	}
+	if _, ok := props["Logs"].(map[string]interface{})["LogGroupName"]; !ok {
+		t.Fatalf("Expected names unique to the stack to be kept got '%v'", props)
+	}
+
+	if names[0].To != "(generated)" || names[0].Action != "removed" {
+		t.Fatalf("Expected the bucket name to be removed got '%v'", names[0])
+	}
+}
+
+func TestRewritePhysicalNamesAcrossRegions(t *testing.T) {
+	scope := rewriteScope{SourceRegion: "us-east-1", TargetRegion: "eu-west-1", SourceAccount: "1", TargetAccount: "1"}
+	_, names, _, err := rewritePhysicalNames(namesTemplate, physicalNamesSuffix, "-clone", scope)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	actions := map[string]string{}
+	for _, n := range names {
+		actions[n.LogicalID] = n.Action
+	}
+	expected := map[string]string{
+		"Bucket": "suffixed",
+		"Jobs":   "unique to the target region",
+		"Logs":   "unique to the stack",
+		"Role":   "suffixed",
+		"Table":  "unique to the target region",
+	}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, actions)
+	}
+}
+
+func TestRewritePhysicalNamesYAML(t *testing.T) {
+	body := `Resources:
+  Fn:
+    Type: AWS::Lambda::Function
+    Properties:
+      FunctionName: !Sub '${Env}-handler'
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      FifoQueue: true
+      QueueName: !Ref QueueName
+`
+	result, names, _, err := rewritePhysicalNames(body, physicalNamesSuffix, "-clone", sameRegion)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !strings.Contains(result, `FunctionName: !Sub '${Env}-handler-clone'`) || !strings.Contains(result, "QueueName: !Ref QueueName") {
+		t.Fatalf("Expected the function to be suffixed got '%s'", result)
+	}
+	if names[1].Action != "collides, a FIFO name is only suffixed as a literal or Fn::Sub" {
+		t.Fatalf("Expected the FIFO queue to be left got '%v'", names[1])
+	}
+}
+
+func TestRewritePhysicalNamesWarnsOfLongNames(t *testing.T) {
+	body := `{"Resources": {"Role": {"Type": "AWS::IAM::Role", "Properties": {"RoleName": "` + strings.Repeat("r", 60) + `"}}}}`
+	_, _, warnings, err := rewritePhysicalNames(body, physicalNamesSuffix, "-clone", sameRegion)
+	if err != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "longer than the 64 characters AWS::IAM::Role allows") {
+		t.Fatalf("Expected a warning about the name's length got '%v' '%v'", warnings, err)
+	}
+}
+
+func TestWritePhysicalNames(t *testing.T) {
+	var b bytes.Buffer
+	writePhysicalNames(&b, "Names:", []physicalName{
+		{LogicalID: "Bucket", Type: "AWS::S3::Bucket", Property: "BucketName", From: "assets", To: "assets-clone", Action: "suffixed"},
+		{LogicalID: "Q", Type: "AWS::SQS::Queue", Property: "QueueName", From: "jobs", Action: "collides"},
+	})
+
+	expected := "Names:\n" +
+		"  Bucket AWS::S3::Bucket.BucketName assets -> assets-clone (suffixed)\n" +
+		"  Q      AWS::SQS::Queue.QueueName  jobs                   (collides)\n"
+	if b.String() != expected {
+		t.Fatalf("Expected '%v' got '%v'", expected, b.String())
+	}
+}
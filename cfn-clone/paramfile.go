@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parametersFile holds the parameters, and for the CodePipeline template
+// configuration format the tags, read from a --parameters-file.
+type parametersFile struct {
+	Parameters map[string]string
+	Tags       map[string]string
+}
+
+// readParametersFile reads a parameters file in any of the supported formats:
+//
+//   - the aws cli array: [{"ParameterKey": "K", "ParameterValue": "V"}]
+//   - a flat key/value map: {"K": "V"}
+//   - a CodePipeline template configuration: {"Parameters": {"K": "V"}, "Tags": {}}
+//
+// Each may be written as JSON or YAML.
+func readParametersFile(path string) (*parametersFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read parameters file '%s'. %s", path, err.Error())
+	}
+
+	f, err := parseParametersFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed parameters file '%s'. %s", path, err.Error())
+	}
+
+	return f, nil
+}
+
+func parseParametersFile(path string, data []byte) (*parametersFile, error) {
+	var doc interface{}
+
+	trimmed := bytes.TrimSpace(data)
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".json" || (ext != ".yaml" && ext != ".yml" && len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{')) {
+		d := json.NewDecoder(bytes.NewReader(trimmed))
+		d.UseNumber()
+		if err := d.Decode(&doc); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	}
+
+	switch d := doc.(type) {
+	case []interface{}:
+		params, err := parametersFromList(d)
+		if err != nil {
+			return nil, err
+		}
+		return &parametersFile{Parameters: params, Tags: map[string]string{}}, nil
+	case map[string]interface{}:
+		if isTemplateConfiguration(d) {
+			return parametersFromTemplateConfiguration(d)
+		}
+		params, err := stringValues("parameter", d)
+		if err != nil {
+			return nil, err
+		}
+		return &parametersFile{Parameters: params, Tags: map[string]string{}}, nil
+	case nil:
+		return nil, fmt.Errorf("File is empty")
+	}
+
+	return nil, fmt.Errorf("Expected a list of ParameterKey/ParameterValue entries or a map of parameters")
+}
+
+// isTemplateConfiguration reports whether doc is in the CodePipeline template
+// configuration format, whose Parameters and Tags are nested maps.
+func isTemplateConfiguration(doc map[string]interface{}) bool {
+	for _, k := range []string{"Parameters", "Tags", "StackPolicy"} {
+		if _, ok := doc[k].(map[string]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parametersFromTemplateConfiguration(doc map[string]interface{}) (*parametersFile, error) {
+	f := &parametersFile{Parameters: map[string]string{}, Tags: map[string]string{}}
+
+	keys := []string{}
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var err error
+		switch k {
+		case "Parameters":
+			m, ok := doc[k].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Parameters must be a map of parameter names to values")
+			}
+			f.Parameters, err = stringValues("parameter", m)
+		case "Tags":
+			m, ok := doc[k].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Tags must be a map of tag keys to values")
+			}
+			f.Tags, err = stringValues("tag", m)
+		case "StackPolicy":
+		default:
+			return nil, fmt.Errorf("Unknown template configuration section '%s'", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func parametersFromList(list []interface{}) (map[string]string, error) {
+	params := map[string]string{}
+
+	for i, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Entry %d must be a map with ParameterKey and ParameterValue", i+1)
+		}
+
+		key, ok := entry["ParameterKey"].(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("Entry %d is missing ParameterKey", i+1)
+		}
+
+		v, ok := entry["ParameterValue"]
+		if !ok {
+			return nil, fmt.Errorf("Entry %d ('%s') is missing ParameterValue", i+1, key)
+		}
+
+		value, err := stringValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("Entry %d ('%s') %s", i+1, key, err.Error())
+		}
+		params[key] = value
+	}
+
+	return params, nil
+}
+
+func stringValues(kind string, m map[string]interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	for k, v := range m {
+		s, err := stringValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("Value of %s '%s' %s", kind, k, err.Error())
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+// stringValue converts a scalar, or a list of scalars for CommaDelimitedList
+// parameters, into the string CloudFormation expects.
+func stringValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case nil:
+		return "", nil
+	case bool, int, int64, json.Number:
+		return fmt.Sprint(t), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case []interface{}:
+		parts := []string{}
+		for _, item := range t {
+			s, err := stringValue(item)
+			if err != nil {
+				return "", err
+			}
+			if _, nested := item.([]interface{}); nested {
+				return "", fmt.Errorf("must not contain nested lists")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	}
+
+	return "", fmt.Errorf("must be a string, number, boolean or list")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var parseParametersFileTcs = []struct {
+	path   string
+	data   string
+	params map[string]string
+	tags   map[string]string
+}{
+	{
+		"params.json",
+		`[{"ParameterKey": "Env", "ParameterValue": "prod"}, {"ParameterKey": "Count", "ParameterValue": 3}]`,
+		map[string]string{"Env": "prod", "Count": "3"},
+		map[string]string{},
+	},
+	{
+		"params.json",
+		`{"Env": "prod", "Subnets": ["a", "b"], "Ratio": 1000000.5, "Enabled": true}`,
+		map[string]string{"Env": "prod", "Subnets": "a,b", "Ratio": "1000000.5", "Enabled": "true"},
+		map[string]string{},
+	},
+	{
+		"params.yaml",
+		"Env: prod\nSubnets:\n  - a\n  - b\nCount: 3\n",
+		map[string]string{"Env": "prod", "Subnets": "a,b", "Count": "3"},
+		map[string]string{},
+	},
+	{
+		"params.yml",
+		"- ParameterKey: Env\n  ParameterValue: prod\n",
+		map[string]string{"Env": "prod"},
+		map[string]string{},
+	},
+	{
+		"config.json",
+		`{"Parameters": {"Env": "prod"}, "Tags": {"Owner": "me"}, "StackPolicy": {"Statement": []}}`,
+		map[string]string{"Env": "prod"},
+		map[string]string{"Owner": "me"},
+	},
+	{
+		"params",
+		`{"Env": "prod"}`,
+		map[string]string{"Env": "prod"},
+		map[string]string{},
+	},
+}
+
+func TestParseParametersFile(t *testing.T) {
+	for _, tc := range parseParametersFileTcs {
+		f, err := parseParametersFile(tc.path, []byte(tc.data))
+		if err != nil {
+			t.Fatalf("Expected no error got '%v' for '%s'", err, tc.data)
+		}
+
+		if !reflect.DeepEqual(f.Parameters, tc.params) {
+			t.Fatalf("Expected '%v' got '%v' for '%s'", tc.params, f.Parameters, tc.data)
+		}
+
+		if !reflect.DeepEqual(f.Tags, tc.tags) {
+			t.Fatalf("Expected tags '%v' got '%v' for '%s'", tc.tags, f.Tags, tc.data)
+		}
+	}
+}
+
+var parseParametersFileErrorTcs = []struct {
+	path string
+	data string
+}{
+	{"params.json", `[{"ParameterKey": "Env"`},
+	{"params.json", `[{"ParameterValue": "prod"}]`},
+	{"params.json", `[{"ParameterKey": "Env"}]`},
+	{"params.json", `["Env=prod"]`},
+	{"params.json", `{"Env": {"nested": "map"}}`},
+	{"params.json", `"just a string"`},
+	{"params.yaml", "Env: [unclosed\n"},
+	{"params.yaml", ""},
+	{"config.json", `{"Parameters": {"Env": "prod"}, "Unknown": {}}`},
+}
+
+func TestParseParametersFileErrors(t *testing.T) {
+	for _, tc := range parseParametersFileErrorTcs {
+		if _, err := parseParametersFile(tc.path, []byte(tc.data)); err == nil {
+			t.Fatalf("Expected error for '%s'", tc.data)
+		}
+	}
+}
+
+func TestReadParametersFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "cfn-clone-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp file for testing readParametersFile")
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	f.WriteString("Env: prod\n")
+	f.Sync()
+
+	p, err := readParametersFile(f.Name())
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if p.Parameters["Env"] != "prod" {
+		t.Fatalf("Expected 'prod' got '%v'", p.Parameters)
+	}
+
+	if _, err := readParametersFile("/no-way-this-exists"); err == nil {
+		t.Fatalf("Expected error for missing file")
+	}
+}
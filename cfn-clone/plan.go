@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	valueAdded     = "+"
+	valueChanged   = "~"
+	valueRemoved   = "-"
+	valueUnchanged = "="
+)
+
+// plan describes everything a clone is about to do, so it can be reviewed
+// before anything is created.
+type plan struct {
+	StackName         string
+	SourceName        string
+	SourceRegion      string
+	TargetRegion      string
+	TemplateSource    string
+	TemplateStaging   string
+	CreatedWith       string
+	Capabilities      []string
+	CapabilityReasons map[string]string
+	Parameters        []valueChange
+	Defaults          map[string]string
+	Rewrites          []rewrite
+	Exports           []rewrite
+	PhysicalNames     []physicalName
+	SSMParameters     []ssmParameter
+	References        map[string]string
+	Tags              []valueChange
+	Settings          []valueChange
+	// Sync is set when the plan updates an existing clone, in which case
+	// the changes are from the clone as it is rather than the source.
+	Sync            bool
+	Kept            []string
+	TemplateChanges []string
+}
+
+// valueChange is the difference of a single parameter or tag between the
+// source stack and the clone.
+type valueChange struct {
+	Action      string
+	Key         string
+	SourceValue string
+	Value       string
+}
+
+// newPlan builds the plan for input, masking every secret parameter value.
+func newPlan(options *options, source *Stack, input *StackInput, redactor *redactor) *plan {
+	p := &plan{
+		StackName:      input.StackName,
+		SourceName:     options.SourceName,
+		SourceRegion:   options.SourceRegion,
+		TargetRegion:   options.TargetRegion,
+		TemplateSource: "source stack '" + options.SourceName + "'",
+		Capabilities:   input.Capabilities,
+		Parameters:     diffValues(source.Parameters, input.Parameters),
+		Tags:           diffValues(source.Tags, input.Tags),
+	}
+
+	for i, c := range p.Parameters {
+		p.Parameters[i].SourceValue = redactor.value(c.Key, c.SourceValue)
+		p.Parameters[i].Value = redactor.value(c.Key, c.Value)
+	}
+
+	if options.Template != "" {
+		p.TemplateSource = "file '" + options.Template + "'"
+	}
+
+	return p
+}
+
+// compareWithClone turns the plan into one for syncing the existing clone
+// current, whose stack policy is given with its settings.
+func (p *plan) compareWithClone(current *Stack, settings StackSettings, input *StackInput, redactor *redactor) {
+	p.Sync = true
+	p.Kept = input.PreviousParameters
+	p.Parameters = diffValues(current.Parameters, input.Parameters)
+	p.Tags = diffValues(current.Tags, input.Tags)
+	p.Settings = diffValues(settingsValues(settings), settingsValues(input.StackSettings))
+
+	for i, c := range p.Parameters {
+		p.Parameters[i].SourceValue = redactor.value(c.Key, c.SourceValue)
+		p.Parameters[i].Value = redactor.value(c.Key, c.Value)
+	}
+}
+
+func diffValues(source map[string]string, values map[string]string) []valueChange {
+	keys := []string{}
+	for k := range source {
+		keys = append(keys, k)
+	}
+	for k := range values {
+		if _, ok := source[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []valueChange{}
+	for _, k := range keys {
+		sv, inSource := source[k]
+		v, inValues := values[k]
+
+		c := valueChange{Key: k, SourceValue: sv, Value: v}
+		switch {
+		case !inSource:
+			c.Action = valueAdded
+		case !inValues:
+			c.Action = valueRemoved
+		case sv != v:
+			c.Action = valueChanged
+		default:
+			c.Action = valueUnchanged
+		}
+		changes = append(changes, c)
+	}
+
+	return changes
+}
+
+func (p *plan) String() string {
+	var b bytes.Buffer
+	w := new(tabwriter.Writer)
+	w.Init(&b, 0, 8, 1, ' ', 0)
+
+	capabilities := strings.Join(p.Capabilities, ", ")
+	if capabilities == "" {
+		capabilities = "none"
+	}
+
+	direction := "source -> clone"
+	if p.Sync {
+		direction = "clone -> synced"
+		fmt.Fprintln(w, "Sync plan:")
+	} else {
+		fmt.Fprintln(w, "Clone plan:")
+	}
+	fmt.Fprintf(w, "  Stack name:\t%s\n", p.StackName)
+	fmt.Fprintf(w, "  Source stack:\t%s\n", p.SourceName)
+	if p.SourceRegion != "" || p.TargetRegion != "" {
+		fmt.Fprintf(w, "  Source region:\t%s\n", regionOrDefault(p.SourceRegion))
+		fmt.Fprintf(w, "  Target region:\t%s\n", regionOrDefault(p.TargetRegion))
+	}
+	fmt.Fprintf(w, "  Template:\t%s\n", p.TemplateSource)
+	if p.TemplateStaging != "" {
+		fmt.Fprintf(w, "  Template staged in:\t%s\n", p.TemplateStaging)
+	}
+	if p.CreatedWith != "" {
+		fmt.Fprintf(w, "  Created with:\t%s\n", p.CreatedWith)
+	}
+	fmt.Fprintf(w, "  Capabilities:\t%s\n", capabilities)
+	for _, c := range p.Capabilities {
+		if reason, ok := p.CapabilityReasons[c]; ok {
+			fmt.Fprintf(w, "    %s:\t%s\n", c, reason)
+		}
+	}
+	w.Flush()
+
+	writeValueChanges(&b, "Parameters", direction, p.Parameters)
+	if len(p.Kept) > 0 {
+		fmt.Fprintf(&b, "\nParameters kept at the clone's values: %s\n", strings.Join(p.Kept, ", "))
+	}
+	if len(p.Defaults) > 0 {
+		b.WriteString("\n" + prettyValues("Parameters using template defaults:", p.Defaults))
+	}
+	writeSSMParameters(&b, p.SSMParameters)
+	if len(p.References) > 0 {
+		b.WriteString("\n" + prettyValues("Parameters resolved before creation:", p.References))
+	}
+	if len(p.Rewrites) > 0 {
+		b.WriteString("\n")
+		writeRewrites(&b, "Values rewritten for the target:", p.Rewrites)
+	}
+	if len(p.Exports) > 0 {
+		b.WriteString("\n")
+		writeRewrites(&b, "Exports renamed for the clone:", p.Exports)
+	}
+	if len(p.PhysicalNames) > 0 {
+		b.WriteString("\n")
+		writePhysicalNames(&b, "Physical resource names:", p.PhysicalNames)
+	}
+	writeValueChanges(&b, "Tags", direction, p.Tags)
+	writeValueChanges(&b, "Stack settings", direction, p.Settings)
+	if p.Sync {
+		writeTemplateChanges(&b, p.TemplateChanges)
+	}
+
+	return b.String()
+}
+
+func writeSSMParameters(b *bytes.Buffer, ssm []ssmParameter) {
+	if len(ssm) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(b, 0, 8, 1, ' ', 0)
+
+	b.WriteString("\nSSM parameters (name -> resolved value):\n")
+	for _, s := range ssm {
+		how := "resolved on deploy"
+		if s.Pinned {
+			how = "pinned"
+		}
+		fmt.Fprintf(w, "  %s\t%s -> %s\t(%s)\n", s.Key, s.Name, s.Resolved, how)
+	}
+	w.Flush()
+}
+
+func regionOrDefault(region string) string {
+	if region == "" {
+		return "default"
+	}
+	return region
+}
+
+func writeTemplateChanges(b *bytes.Buffer, changes []string) {
+	if len(changes) == 0 {
+		b.WriteString("\nTemplate changes: none\n")
+		return
+	}
+
+	b.WriteString("\nTemplate changes (clone -> synced):\n")
+	for _, l := range changes {
+		fmt.Fprintf(b, "  %s\n", l)
+	}
+}
+
+func writeValueChanges(b *bytes.Buffer, title string, direction string, changes []valueChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(b, 0, 8, 1, ' ', 0)
+
+	fmt.Fprintf(b, "\n%s (%s):\n", title, direction)
+	for _, c := range changes {
+		switch c.Action {
+		case valueAdded:
+			fmt.Fprintf(w, "  %s %s\t%s\n", c.Action, c.Key, c.Value)
+		case valueRemoved:
+			fmt.Fprintf(w, "  %s %s\t%s\n", c.Action, c.Key, c.SourceValue)
+		case valueChanged:
+			fmt.Fprintf(w, "  %s %s\t%s -> %s\n", c.Action, c.Key, c.SourceValue, c.Value)
+		default:
+			fmt.Fprintf(w, "  %s %s\t%s\n", c.Action, c.Key, c.Value)
+		}
+	}
+	w.Flush()
+}
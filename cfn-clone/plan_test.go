@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestDiffValues(t *testing.T) {
+	source := map[string]string{"same": "1", "changed": "2", "removed": "3"}
+	params := map[string]string{"same": "1", "changed": "4", "added": "5"}
+
+	expected := []valueChange{
+		{valueAdded, "added", "", "5"},
+		{valueChanged, "changed", "2", "4"},
+		{valueRemoved, "removed", "3", ""},
+		{valueUnchanged, "same", "1", "1"},
+	}
+
+	changes := diffValues(source, params)
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, changes)
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	opts := &options{SourceName: "source", NewName: "clone", Template: "./new.json"}
+	input := &StackInput{
+		StackName:    "clone",
+		Parameters:   map[string]string{"foo": "override"},
+		Capabilities: []string{"CAPABILITY_IAM"},
+	}
+
+	source := &Stack{Parameters: map[string]string{"foo": "bar"}, Tags: map[string]string{"Owner": "me"}}
+	input.Tags = map[string]string{"Owner": "you"}
+
+	out := newPlan(opts, source, input, testRedactor()).String()
+
+	patterns := []string{
+		`Stack name:\s+clone\n`,
+		`Source stack:\s+source\n`,
+		`Template:\s+file './new.json'\n`,
+		`Capabilities:\s+CAPABILITY_IAM\n`,
+		`~ foo\s+bar -> override\n`,
+		`Tags \(source -> clone\):\n\s+~ Owner\s+me -> you\n`,
+	}
+	for _, p := range patterns {
+		if !regexp.MustCompile(p).MatchString(out) {
+			t.Fatalf("Expected '%s' in '%s'", p, out)
+		}
+	}
+}
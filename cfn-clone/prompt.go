@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// promptAttempts is how many times a value is asked for before giving up.
+const promptAttempts = 3
+
+// prompter asks the user for parameter values. clone only prompts when it
+// is given one, which main does when stdin is a terminal.
+type prompter interface {
+	prompt(label string) (string, error)
+	promptHidden(label string) (string, error)
+}
+
+// terminalPrompter reads answers line by line from a terminal, turning off
+// echo while hidden values are typed.
+type terminalPrompter struct {
+	in      *bufio.Reader
+	out     io.Writer
+	setEcho func(on bool) error
+}
+
+func newTerminalPrompter(in *os.File, out io.Writer) *terminalPrompter {
+	return &terminalPrompter{
+		in:  bufio.NewReader(in),
+		out: out,
+		setEcho: func(on bool) error {
+			mode := "-echo"
+			if on {
+				mode = "echo"
+			}
+			cmd := exec.Command("stty", mode)
+			cmd.Stdin = in
+			return cmd.Run()
+		},
+	}
+}
+
+// stdinPrompter returns a prompter reading from in, or nil when in is not a
+// terminal so nothing waits for answers that never come.
+func stdinPrompter(in *os.File, out io.Writer) prompter {
+	if !isTerminal(in) {
+		return nil
+	}
+	return newTerminalPrompter(in, out)
+}
+
+func (p *terminalPrompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (p *terminalPrompter) prompt(label string) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", label)
+	return p.readLine()
+}
+
+func (p *terminalPrompter) promptHidden(label string) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", label)
+
+	if err := p.setEcho(false); err != nil {
+		return "", fmt.Errorf("Unable to hide input. %s", err.Error())
+	}
+	defer p.setEcho(true)
+
+	v, err := p.readLine()
+	fmt.Fprintln(p.out)
+	return v, err
+}
+
+// promptHiddenConfirmed asks for a hidden value twice, until both match.
+func promptHiddenConfirmed(p prompter, label string, out io.Writer) (string, error) {
+	for i := 0; i < promptAttempts; i++ {
+		v, err := p.promptHidden(label)
+		if err != nil {
+			return "", err
+		}
+
+		confirmed, err := p.promptHidden("Confirm " + label)
+		if err != nil {
+			return "", err
+		}
+
+		if v == confirmed {
+			return v, nil
+		}
+		fmt.Fprintln(out, "The values do not match, try again.")
+	}
+
+	return "", fmt.Errorf("The values for %s did not match", label)
+}
+
+// promptParameter asks for the value of parameter key, describing it from
+// the template when known. NoEcho parameters are read hidden and confirmed.
+// Values are checked against the template's constraints before returning.
+func promptParameter(p prompter, t *cfnTemplate, key string, noEcho bool, out io.Writer) (string, error) {
+	param, declared := templateParameter{}, false
+	if t != nil {
+		param, declared = t.Parameters[key]
+	}
+
+	fmt.Fprintf(out, "\nParameter '%s'", key)
+	if declared && param.Type != "" {
+		fmt.Fprintf(out, " (%s)", param.Type)
+	}
+	fmt.Fprintln(out)
+	if param.Description != "" {
+		fmt.Fprintf(out, "  %s\n", param.Description)
+	}
+	if len(param.AllowedValues) > 0 {
+		fmt.Fprintf(out, "  Allowed values: %s\n", allowedValuesString(param.AllowedValues))
+	}
+
+	hidden := noEcho || bool(param.NoEcho)
+	for i := 0; i < promptAttempts; i++ {
+		var v string
+		var err error
+		if hidden {
+			v, err = promptHiddenConfirmed(p, key, out)
+		} else {
+			v, err = p.prompt(key)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if !declared {
+			return v, nil
+		}
+
+		violations := validateParameterConstraints(param, v)
+		if len(violations) == 0 {
+			return v, nil
+		}
+		if hidden {
+			violations = maskViolations(violations, v)
+		}
+		fmt.Fprintf(out, "Invalid value, %s.\n", strings.Join(violations, "; "))
+		if param.ConstraintDescription != "" {
+			fmt.Fprintf(out, "  %s\n", param.ConstraintDescription)
+		}
+	}
+
+	return "", fmt.Errorf("No valid value was given for parameter '%s'", key)
+}
+
+// maskViolations masks a rejected hidden value, and each of its list
+// elements, in the violations found for it. The value is not yet known to
+// the redactor, which only learns of it once it is accepted.
+func maskViolations(violations []string, value string) []string {
+	values := []string{value}
+	for _, v := range strings.Split(value, ",") {
+		values = append(values, strings.TrimSpace(v))
+	}
+
+	masked := []string{}
+	for _, m := range violations {
+		for _, v := range values {
+			m = strings.Replace(m, "'"+v+"'", "'"+redactedValue+"'", -1)
+		}
+		masked = append(masked, m)
+	}
+	return masked
+}
+
+// promptForNoEcho asks for the masked NoEcho values copied from the source
+// stack, as those can never be cloned as they are.
+func promptForNoEcho(p prompter, t *cfnTemplate, params map[string]string, masked []string, redactor *redactor, out io.Writer) error {
+	for _, k := range masked {
+		v, err := promptParameter(p, t, k, true, out)
+		if err != nil {
+			return err
+		}
+		redactor.addKeys(k)
+		redactor.addValue(v)
+		params[k] = v
+	}
+
+	return nil
+}
+
+// promptForMissing asks for every required parameter that has no value.
+func promptForMissing(p prompter, t *cfnTemplate, params map[string]string, r *reconciliation, redactor *redactor, out io.Writer) error {
+	if len(r.Missing) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out, "The template requires parameters with no value or default.")
+	for _, k := range r.Missing {
+		v, err := promptParameter(p, t, k, false, out)
+		if err != nil {
+			return err
+		}
+		if t.Parameters[k].NoEcho {
+			redactor.addValue(v)
+		}
+		params[k] = v
+	}
+	r.Missing = nil
+
+	return nil
+}
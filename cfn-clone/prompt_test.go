@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptedPrompter answers prompts from a list, recording what was asked.
+type scriptedPrompter struct {
+	answers []string
+	asked   []string
+}
+
+func (p *scriptedPrompter) next(label string) (string, error) {
+	p.asked = append(p.asked, label)
+	if len(p.answers) == 0 {
+		return "", io.EOF
+	}
+	v := p.answers[0]
+	p.answers = p.answers[1:]
+	return v, nil
+}
+
+func (p *scriptedPrompter) prompt(label string) (string, error) {
+	return p.next(label)
+}
+
+func (p *scriptedPrompter) promptHidden(label string) (string, error) {
+	return p.next("hidden " + label)
+}
+
+func TestTerminalPrompterHidesInput(t *testing.T) {
+	var out bytes.Buffer
+	echo := []bool{}
+	p := &terminalPrompter{
+		in:      bufio.NewReader(strings.NewReader("visible\nhidden\r\n")),
+		out:     &out,
+		setEcho: func(on bool) error { echo = append(echo, on); return nil },
+	}
+
+	if v, err := p.prompt("Name"); v != "visible" || err != nil {
+		t.Fatalf("Expected 'visible' got '%v' (%v)", v, err)
+	}
+
+	if v, err := p.promptHidden("Password"); v != "hidden" || err != nil {
+		t.Fatalf("Expected 'hidden' got '%v' (%v)", v, err)
+	}
+
+	if len(echo) != 2 || echo[0] || !echo[1] {
+		t.Fatalf("Expected echo to be turned off then on got '%v'", echo)
+	}
+
+	if _, err := p.prompt("Name"); err != io.EOF {
+		t.Fatalf("Expected '%v' got '%v'", io.EOF, err)
+	}
+}
+
+func TestPromptHiddenConfirmed(t *testing.T) {
+	var out bytes.Buffer
+	p := &scriptedPrompter{answers: []string{"one", "two", "three", "three"}}
+
+	v, err := promptHiddenConfirmed(p, "password", &out)
+	if v != "three" || err != nil {
+		t.Fatalf("Expected 'three' got '%v' (%v)", v, err)
+	}
+
+	if !strings.Contains(out.String(), "do not match") {
+		t.Fatalf("Expected a mismatch message got '%v'", out.String())
+	}
+
+	p = &scriptedPrompter{answers: []string{"a", "b", "a", "b", "a", "b"}}
+	if _, err = promptHiddenConfirmed(p, "password", &out); err == nil {
+		t.Fatalf("Expected an error after repeated mismatches")
+	}
+}
+
+func TestPromptParameter(t *testing.T) {
+	tmpl, err := parseTemplate(`{"Parameters": {"Size": {"Type": "String", "Description": "Instance size", "AllowedValues": ["small", "large"]}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	var out bytes.Buffer
+	p := &scriptedPrompter{answers: []string{"huge", "large"}}
+	v, err := promptParameter(p, tmpl, "Size", false, &out)
+	if v != "large" || err != nil {
+		t.Fatalf("Expected 'large' got '%v' (%v)", v, err)
+	}
+
+	for _, expected := range []string{"Instance size", "Allowed values: [small, large]", "'huge' is not one of"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected '%v' got '%v'", expected, out.String())
+		}
+	}
+}
+
+func TestPromptForNoEchoHidesRejectedValues(t *testing.T) {
+	tmpl, err := parseTemplate(`{"Parameters": {"DbPassword": {"Type": "String", "NoEcho": true, "AllowedPattern": "[a-z]+"}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	var out bytes.Buffer
+	redactor := testRedactor()
+	params := map[string]string{"DbPassword": redactedValue}
+	p := &scriptedPrompter{answers: []string{"Hunter2", "Hunter2", "hunter", "hunter"}}
+	if err = promptForNoEcho(p, tmpl, params, []string{"DbPassword"}, redactor, redactor.writer(&out)); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if params["DbPassword"] != "hunter" {
+		t.Fatalf("Expected 'hunter' got '%v'", params["DbPassword"])
+	}
+
+	if strings.Contains(out.String(), "Hunter2") || !strings.Contains(out.String(), "Invalid value, '****' does not match AllowedPattern '[a-z]+'.") {
+		t.Fatalf("Expected the rejected value to be masked got '%v'", out.String())
+	}
+}
+
+func TestMaskViolations(t *testing.T) {
+	violations := []string{"'a1' is not a number", "length 5 is longer than MaxLength 4"}
+	expected := []string{"'****' is not a number", "length 5 is longer than MaxLength 4"}
+	if masked := maskViolations(violations, "a1, 2"); !reflect.DeepEqual(masked, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, masked)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Unable to open %s: %v", os.DevNull, err)
+	}
+	defer null.Close()
+	if isTerminal(null) {
+		t.Fatalf("Expected %s not to be a terminal", os.DevNull)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to open a pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if isTerminal(r) {
+		t.Fatalf("Expected a pipe not to be a terminal")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// reconciliation records how merged parameters were fitted to a template.
+type reconciliation struct {
+	Dropped  []string
+	Missing  []string
+	Defaults map[string]string
+}
+
+// reconcileParameters drops parameters the template does not declare, unless
+// listed in keep, and finds declared ones without a value, which either fall
+// back to their template default or are missing.
+func reconcileParameters(t *cfnTemplate, params map[string]string, keep map[string]string) (map[string]string, *reconciliation) {
+	r := &reconciliation{Dropped: []string{}, Missing: []string{}, Defaults: map[string]string{}}
+	reconciled := map[string]string{}
+
+	for k, v := range params {
+		_, declared := t.Parameters[k]
+		_, kept := keep[k]
+		if declared || kept {
+			reconciled[k] = v
+		} else {
+			r.Dropped = append(r.Dropped, k)
+		}
+	}
+
+	for k, p := range t.Parameters {
+		if _, ok := reconciled[k]; ok {
+			continue
+		}
+
+		if p.Default == nil {
+			r.Missing = append(r.Missing, k)
+			continue
+		}
+
+		d, err := stringValue(p.Default)
+		if err != nil {
+			d = allowedValueString(p.Default)
+		}
+		r.Defaults[k] = d
+	}
+
+	sort.Strings(r.Dropped)
+	sort.Strings(r.Missing)
+
+	return reconciled, r
+}
+
+// missingError reports the required parameters that have no value.
+func (r *reconciliation) missingError(t *cfnTemplate) error {
+	if len(r.Missing) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	for _, k := range r.Missing {
+		line := "  " + k
+		if d := t.Parameters[k].Description; d != "" {
+			line += ": " + d
+		}
+		lines = append(lines, line)
+	}
+
+	return errors.New("The template requires parameters with no value or default, set them with -a or --parameters-file:\n" + strings.Join(lines, "\n"))
+}
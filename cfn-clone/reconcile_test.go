@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReconcileParameters(t *testing.T) {
+	tmpl, err := parseTemplate(`{
+		"Parameters": {
+			"Kept": {"Type": "String"},
+			"WithDefault": {"Type": "Number", "Default": 5},
+			"Required": {"Type": "String", "Description": "Something we need"}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	params, r := reconcileParameters(tmpl, map[string]string{"Kept": "1", "Removed": "2", "Unknown": "3"}, map[string]string{"Unknown": "3"})
+
+	if !reflect.DeepEqual(params, map[string]string{"Kept": "1", "Unknown": "3"}) {
+		t.Fatalf("Expected only declared parameters got '%v'", params)
+	}
+
+	if !reflect.DeepEqual(r.Dropped, []string{"Removed"}) {
+		t.Fatalf("Expected 'Removed' to be dropped got '%v'", r.Dropped)
+	}
+
+	if !reflect.DeepEqual(r.Missing, []string{"Required"}) {
+		t.Fatalf("Expected 'Required' to be missing got '%v'", r.Missing)
+	}
+
+	if !reflect.DeepEqual(r.Defaults, map[string]string{"WithDefault": "5"}) {
+		t.Fatalf("Expected 'WithDefault' to use its default got '%v'", r.Defaults)
+	}
+
+	err = r.missingError(tmpl)
+	if err == nil || !strings.Contains(err.Error(), "Required: Something we need") {
+		t.Fatalf("Expected missing parameter error got '%v'", err)
+	}
+}
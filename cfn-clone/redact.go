@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "****"
+
+// redactor keeps track of secret parameters and masks their values in
+// anything cfn-clone prints.
+type redactor struct {
+	pattern *regexp.Regexp
+	keys    map[string]bool
+	values  map[string]bool
+}
+
+func newRedactor(pattern string) (*redactor, error) {
+	r := &redactor{keys: map[string]bool{}, values: map[string]bool{}}
+
+	if pattern != "" {
+		p, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.pattern = p
+	}
+
+	return r, nil
+}
+
+// addKeys marks the given parameter keys as secret.
+func (r *redactor) addKeys(keys ...string) {
+	for _, k := range keys {
+		r.keys[k] = true
+	}
+}
+
+// addValue marks a value as secret, wherever it shows up. The comma escaped
+// form used on the aws cli command line is masked too.
+func (r *redactor) addValue(v string) {
+	if v != "" && v != redactedValue {
+		r.values[v] = true
+		r.values[strings.Replace(v, ",", "\\,", -1)] = true
+	}
+}
+
+func (r *redactor) isSecret(key string) bool {
+	return r.keys[key] || (r.pattern != nil && r.pattern.MatchString(key))
+}
+
+// track records the values of every secret key in params, so they are
+// scrubbed from free form output such as aws cli errors.
+func (r *redactor) track(params map[string]string) {
+	for k, v := range params {
+		if r.isSecret(k) {
+			r.addValue(v)
+		}
+	}
+}
+
+// value returns v masked if key is secret.
+func (r *redactor) value(key string, v string) string {
+	if r.isSecret(key) && v != "" {
+		return redactedValue
+	}
+	return v
+}
+
+// parameters returns a copy of params with secret values masked.
+func (r *redactor) parameters(params map[string]string) map[string]string {
+	masked := map[string]string{}
+	for k, v := range params {
+		masked[k] = r.value(k, v)
+	}
+	return masked
+}
+
+// String masks every known secret value in s.
+func (r *redactor) String(s string) string {
+	values := []string{}
+	for v := range r.values {
+		values = append(values, v)
+	}
+
+	// replace longer values first so a secret containing another is fully masked
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, v := range values {
+		s = strings.Replace(s, v, redactedValue, -1)
+	}
+	return s
+}
+
+// writer wraps w so everything written through it has secrets masked.
+func (r *redactor) writer(w io.Writer) io.Writer {
+	return &redactingWriter{w: w, r: r}
+}
+
+type redactingWriter struct {
+	w io.Writer
+	r *redactor
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
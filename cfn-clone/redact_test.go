@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func testRedactor() *redactor {
+	r, _ := newRedactor("")
+	return r
+}
+
+func TestRedactorParameters(t *testing.T) {
+	r, err := newRedactor(`(?i)password`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	r.addKeys("ApiKey")
+
+	params := map[string]string{"DbPassword": "hunter2", "ApiKey": "abc123", "Env": "prod", "Empty": ""}
+	expected := map[string]string{"DbPassword": "****", "ApiKey": "****", "Env": "prod", "Empty": ""}
+
+	masked := r.parameters(params)
+
+	if !reflect.DeepEqual(masked, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, masked)
+	}
+}
+
+var redactStringTcs = []struct {
+	in     string
+	result string
+}{
+	{"no secrets here", "no secrets here"},
+	{"ParameterValue=\"hunter2\"", "ParameterValue=\"****\""},
+	{"ParameterValue=\"a\\,b\"", "ParameterValue=\"****\""},
+	{"hunter2 and hunter2x", "**** and ****"},
+}
+
+func TestRedactorString(t *testing.T) {
+	r := testRedactor()
+	r.addKeys("Password", "Other", "Comma")
+	r.track(map[string]string{"Password": "hunter2", "Other": "hunter2x", "Comma": "a,b", "Env": "prod"})
+
+	for _, tc := range redactStringTcs {
+		if s := r.String(tc.in); s != tc.result {
+			t.Fatalf("Expected '%s' got '%s'", tc.result, s)
+		}
+	}
+}
+
+func TestRedactorWriter(t *testing.T) {
+	r := testRedactor()
+	r.addValue("hunter2")
+
+	var b bytes.Buffer
+	w := r.writer(&b)
+	w.Write([]byte("Error: 'exit status 255'. Output: 'bad value hunter2'"))
+
+	expected := "Error: 'exit status 255'. Output: 'bad value ****'"
+	if b.String() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, b.String())
+	}
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	if _, err := newRedactor("("); err == nil {
+		t.Fatalf("Expected error for invalid pattern")
+	}
+}
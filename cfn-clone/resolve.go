@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	envPrefix            = "env:"
+	filePrefix           = "file:"
+	ssmPrefix            = "ssm:"
+	secretsManagerPrefix = "secretsmanager:"
+	// rawPrefix marks an -a value to be used as written, for values that
+	// start like a reference.
+	rawPrefix = "raw:"
+)
+
+// isResolverReference reports whether an -a value names where to read the
+// real value from, rather than being the value itself.
+func isResolverReference(v string) bool {
+	for _, prefix := range []string{envPrefix, filePrefix, ssmPrefix, secretsManagerPrefix} {
+		if strings.HasPrefix(v, prefix) && len(v) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterReferences returns the overrides whose values are references,
+// stripping the raw: prefix from those to be used as written.
+func parameterReferences(overrides map[string]string) map[string]string {
+	references := map[string]string{}
+	for k, v := range overrides {
+		if strings.HasPrefix(v, rawPrefix) {
+			overrides[k] = strings.TrimPrefix(v, rawPrefix)
+		} else if isResolverReference(v) {
+			references[k] = v
+		}
+	}
+	return references
+}
+
+// resolveValue reads the value a reference points at. SSM and Secrets
+// Manager values are read through store.
+func resolveValue(reference string, store secretStore) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, envPrefix):
+		name := strings.TrimPrefix(reference, envPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("Environment variable '%s' is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(reference, filePrefix):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(reference, filePrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	}
+
+	if store == nil {
+		return "", fmt.Errorf("The backend can not read SSM parameters or secrets")
+	}
+
+	if strings.HasPrefix(reference, ssmPrefix) {
+		return store.GetParameter(strings.TrimPrefix(reference, ssmPrefix))
+	}
+
+	id := strings.TrimPrefix(reference, secretsManagerPrefix)
+	field := ""
+	if i := strings.LastIndex(id, "#"); i >= 0 {
+		id, field = id[:i], id[i+1:]
+	}
+
+	secret, err := store.GetSecretValue(id)
+	if err != nil || field == "" {
+		return secret, err
+	}
+
+	fields := map[string]interface{}{}
+	if err = json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("Secret '%s' is not a JSON object, so has no field '%s'", id, field)
+	}
+
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("Secret '%s' has no field '%s'", id, field)
+	}
+
+	s, err := stringValue(v)
+	if err != nil {
+		return "", fmt.Errorf("Field '%s' of secret '%s' %s", field, id, err.Error())
+	}
+	return s, nil
+}
+
+// resolveParameters replaces every referenced parameter with its resolved
+// value. Resolved values are treated as secrets from then on.
+func resolveParameters(params map[string]string, references map[string]string, store secretStore, redactor *redactor) error {
+	keys := []string{}
+	for k := range references {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, err := resolveValue(references[k], store)
+		if err != nil {
+			return fmt.Errorf("Unable to resolve parameter '%s' from '%s'. %s", k, references[k], err.Error())
+		}
+
+		redactor.addKeys(k)
+		redactor.addValue(v)
+		params[k] = v
+	}
+
+	return nil
+}
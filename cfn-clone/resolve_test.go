@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var isResolverReferenceTcs = []struct {
+	in       string
+	expected bool
+}{
+	{"plain", false},
+	{"env:", false},
+	{"env:DB_PASS", true},
+	{"file:./pw.txt", true},
+	{"ssm:/app/db/pass", true},
+	{"secretsmanager:arn:aws:secretsmanager:us-east-1:111111111111:secret:db#password", true},
+	{"arn:aws:sns:us-east-1:111111111111:alerts", false},
+}
+
+func TestIsResolverReference(t *testing.T) {
+	for _, tc := range isResolverReferenceTcs {
+		if v := isResolverReference(tc.in); v != tc.expected {
+			t.Fatalf("Expected '%v' got '%v' for '%v'", tc.expected, v, tc.in)
+		}
+	}
+}
+
+func TestParameterReferences(t *testing.T) {
+	overrides := map[string]string{"a": "ssm:/app/a", "b": "raw:ssm:/app/b", "c": "plain", "d": "raw:raw:x"}
+	references := parameterReferences(overrides)
+
+	expected := map[string]string{"a": "ssm:/app/a"}
+	if !reflect.DeepEqual(references, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, references)
+	}
+
+	expected = map[string]string{"a": "ssm:/app/a", "b": "ssm:/app/b", "c": "plain", "d": "raw:x"}
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, overrides)
+	}
+}
+
+func TestResolveValue(t *testing.T) {
+	os.Setenv("CFN_CLONE_TEST_PASS", "from-env")
+	defer os.Unsetenv("CFN_CLONE_TEST_PASS")
+
+	f, err := ioutil.TempFile("", "cfn-clone-secret")
+	if err != nil {
+		t.Fatalf("Unable to create temp file. %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("from-file\n")
+	f.Close()
+
+	store := newFakeClient()
+	store.ssm["/app/db/pass"] = "from-ssm"
+	store.secrets["arn:aws:secretsmanager:us-east-1:111111111111:secret:db"] = `{"password": "from-secret", "port": 5432}`
+	store.secrets["plain"] = "whole-secret"
+
+	tcs := []struct {
+		reference string
+		expected  string
+		isError   bool
+	}{
+		{"env:CFN_CLONE_TEST_PASS", "from-env", false},
+		{"env:CFN_CLONE_TEST_MISSING", "", true},
+		{"file:" + f.Name(), "from-file", false},
+		{"file:/no-way-this-exists", "", true},
+		{"ssm:/app/db/pass", "from-ssm", false},
+		{"ssm:/app/missing", "", true},
+		{"secretsmanager:arn:aws:secretsmanager:us-east-1:111111111111:secret:db#password", "from-secret", false},
+		{"secretsmanager:arn:aws:secretsmanager:us-east-1:111111111111:secret:db#port", "5432", false},
+		{"secretsmanager:arn:aws:secretsmanager:us-east-1:111111111111:secret:db#missing", "", true},
+		{"secretsmanager:plain", "whole-secret", false},
+		{"secretsmanager:plain#field", "", true},
+	}
+
+	for _, tc := range tcs {
+		v, err := resolveValue(tc.reference, store)
+		if v != tc.expected || (err != nil) != tc.isError {
+			t.Fatalf("Expected '%v' (error %v) got '%v' (%v) for '%v'", tc.expected, tc.isError, v, err, tc.reference)
+		}
+	}
+
+	if _, err = resolveValue("ssm:/app/db/pass", nil); err == nil {
+		t.Fatalf("Expected an error resolving without a secret store")
+	}
+}
+
+func TestResolveParameters(t *testing.T) {
+	store := newFakeClient()
+	store.ssm["/app/db/pass"] = "hunter2"
+
+	r := testRedactor()
+	params := map[string]string{"Db": "ssm:/app/db/pass", "Name": "app"}
+	if err := resolveParameters(params, map[string]string{"Db": "ssm:/app/db/pass"}, store, r); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if params["Db"] != "hunter2" || params["Name"] != "app" {
+		t.Fatalf("Expected resolved parameters got '%v'", params)
+	}
+
+	if r.value("Db", params["Db"]) != redactedValue || r.String("pass is hunter2") != "pass is "+redactedValue {
+		t.Fatalf("Expected resolved values to be redacted")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// regionalIdPattern matches resource IDs that only exist in one region and
+// account, such as AMIs, subnets and security groups.
+var regionalIdPattern = regexp.MustCompile(`^(ami|subnet|sg|vpc|snap|vol|eni|igw|nat|rtb|acl|eipalloc|lt|pl|tgw|vpce)-[0-9a-f]{8,17}$`)
+
+// rewriteScope is the region and account a clone moves from and to. Empty
+// fields are unknown and never rewritten.
+type rewriteScope struct {
+	SourceRegion  string
+	TargetRegion  string
+	SourceAccount string
+	TargetAccount string
+}
+
+// rewrite records a parameter value changed for the target.
+type rewrite struct {
+	Key    string
+	From   string
+	To     string
+	Reason string
+}
+
+// arn is a parsed Amazon Resource Name.
+type arn struct {
+	Partition string
+	Service   string
+	Region    string
+	Account   string
+	Resource  string
+}
+
+func parseArn(s string) (*arn, bool) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[2] == "" {
+		return nil, false
+	}
+
+	return &arn{
+		Partition: parts[1],
+		Service:   parts[2],
+		Region:    parts[3],
+		Account:   parts[4],
+		Resource:  parts[5],
+	}, true
+}
+
+func (a *arn) String() string {
+	return strings.Join([]string{"arn", a.Partition, a.Service, a.Region, a.Account, a.Resource}, ":")
+}
+
+// accountFromArn returns the account of an ARN such as a stack ID or role.
+func accountFromArn(s string) string {
+	if a, ok := parseArn(s); ok {
+		return a.Account
+	}
+	return ""
+}
+
+// regionFromArn returns the region of an ARN such as a stack ID.
+func regionFromArn(s string) string {
+	if a, ok := parseArn(s); ok {
+		return a.Region
+	}
+	return ""
+}
+
+// newRewriteScope works out where a clone moves from, using the source stack
+// ID, and to, using the target options. An unset target region is the
+// source region unless only --source-region was given, in which case it is
+// the region from the environment.
+func newRewriteScope(options *options, source *Stack) rewriteScope {
+	s := rewriteScope{
+		SourceRegion:  regionFromArn(source.StackId),
+		SourceAccount: accountFromArn(source.StackId),
+		TargetRegion:  options.TargetRegion,
+		TargetAccount: options.TargetAccountID,
+	}
+
+	if s.TargetRegion == "" {
+		if options.SourceRegion == "" {
+			s.TargetRegion = s.SourceRegion
+		} else if s.TargetRegion = os.Getenv("AWS_REGION"); s.TargetRegion == "" {
+			s.TargetRegion = os.Getenv("AWS_DEFAULT_REGION")
+		}
+	}
+
+	if s.TargetAccount == "" {
+		if options.TargetRoleARN != "" {
+			s.TargetAccount = accountFromArn(options.TargetRoleARN)
+		} else if options.SourceRoleARN == "" && options.SourceProfile == options.TargetProfile {
+			s.TargetAccount = s.SourceAccount
+		}
+	}
+
+	return s
+}
+
+func (s rewriteScope) crossesRegion() bool {
+	return s.SourceRegion != "" && s.TargetRegion != "" && s.SourceRegion != s.TargetRegion
+}
+
+func (s rewriteScope) crossesAccount() bool {
+	return s.SourceAccount != "" && s.TargetAccount != "" && s.SourceAccount != s.TargetAccount
+}
+
+// rewriteValue translates a single value through the mapping, or moves an
+// ARN into the target region and account.
+func rewriteValue(v string, scope rewriteScope, mapping map[string]string) (string, string) {
+	if to, ok := mapping[v]; ok {
+		return to, "mapping"
+	}
+
+	a, ok := parseArn(v)
+	if !ok {
+		return v, ""
+	}
+
+	reasons := []string{}
+	if scope.crossesRegion() && a.Region == scope.SourceRegion {
+		a.Region = scope.TargetRegion
+		reasons = append(reasons, "region")
+	}
+	if scope.crossesAccount() && a.Account == scope.SourceAccount {
+		a.Account = scope.TargetAccount
+		reasons = append(reasons, "account")
+	}
+
+	if len(reasons) == 0 {
+		return v, ""
+	}
+	return a.String(), "arn " + strings.Join(reasons, " and ")
+}
+
+// rewriteParameters rewrites the values of params, other than the keys in
+// skip, for the target region and account. Comma separated values are
+// rewritten element by element. It returns the rewritten parameters, every
+// rewrite made, and warnings for region specific IDs left untranslated.
+func rewriteParameters(params map[string]string, skip map[string]bool, scope rewriteScope, mapping map[string]string) (map[string]string, []rewrite, []string) {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rewritten := map[string]string{}
+	rewrites := []rewrite{}
+	warnings := []string{}
+
+	for _, k := range keys {
+		v := params[k]
+		if skip[k] {
+			rewritten[k] = v
+			continue
+		}
+
+		elements := strings.Split(v, ",")
+		reasons := []string{}
+		for i, e := range elements {
+			to, reason := rewriteValue(e, scope, mapping)
+			if reason == "" {
+				if (scope.crossesRegion() || scope.crossesAccount()) && regionalIdPattern.MatchString(e) {
+					warnings = append(warnings, fmt.Sprintf("parameter '%s' value '%s' is specific to the source region and account, add it to --rewrite-map", k, e))
+				}
+				continue
+			}
+			elements[i] = to
+			reasons = append(reasons, reason)
+		}
+
+		rewritten[k] = strings.Join(elements, ",")
+		if rewritten[k] != v {
+			rewrites = append(rewrites, rewrite{Key: k, From: v, To: rewritten[k], Reason: strings.Join(uniqueStrings(reasons), ", ")})
+		}
+	}
+
+	return rewritten, rewrites, warnings
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// redactRewrites masks the values of rewrites to secret parameters.
+func redactRewrites(redactor *redactor, rewrites []rewrite) []rewrite {
+	redacted := []rewrite{}
+	for _, r := range rewrites {
+		r.From = redactor.value(r.Key, r.From)
+		r.To = redactor.value(r.Key, r.To)
+		redacted = append(redacted, r)
+	}
+	return redacted
+}
+
+func writeRewrites(b *bytes.Buffer, title string, rewrites []rewrite) {
+	if len(rewrites) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(b, 0, 8, 1, ' ', 0)
+
+	b.WriteString(title + "\n")
+	for _, r := range rewrites {
+		fmt.Fprintf(w, "  %s\t%s -> %s\t(%s)\n", r.Key, r.From, r.To, r.Reason)
+	}
+	w.Flush()
+}
+
+func prettyRewrites(redactor *redactor, rewrites []rewrite) string {
+	var b bytes.Buffer
+	writeRewrites(&b, "The rewritten parameters are:", redactRewrites(redactor, rewrites))
+	return b.String()
+}
+
+// readRewriteMap reads a JSON or YAML map of source values, such as AMI or
+// subnet IDs, to the values to use in the target.
+func readRewriteMap(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read rewrite map '%s'. %s", path, err.Error())
+	}
+
+	mapping := map[string]string{}
+	if err = yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("Malformed rewrite map '%s', expected a map of source to target values. %s", path, err.Error())
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var crossScope = rewriteScope{
+	SourceRegion:  "us-east-1",
+	TargetRegion:  "eu-west-1",
+	SourceAccount: "111111111111",
+	TargetAccount: "222222222222",
+}
+
+var rewriteValueTcs = []struct {
+	value    string
+	scope    rewriteScope
+	mapping  map[string]string
+	expected string
+	reason   string
+}{
+	{"plain", crossScope, nil, "plain", ""},
+	{"arn:aws:sns:us-east-1:111111111111:alerts", crossScope, nil, "arn:aws:sns:eu-west-1:222222222222:alerts", "arn region and account"},
+	{"arn:aws:iam::111111111111:role/app", crossScope, nil, "arn:aws:iam::222222222222:role/app", "arn account"},
+	{"arn:aws:s3:::bucket/key", crossScope, nil, "arn:aws:s3:::bucket/key", ""},
+	{"arn:aws:sns:us-west-2:333333333333:other", crossScope, nil, "arn:aws:sns:us-west-2:333333333333:other", ""},
+	{"arn:aws:sns:us-east-1:111111111111:alerts", rewriteScope{SourceRegion: "us-east-1", TargetRegion: "us-east-1"}, nil, "arn:aws:sns:us-east-1:111111111111:alerts", ""},
+	{"arn:aws:logs:us-east-1:111111111111:log-group:app:*", crossScope, nil, "arn:aws:logs:eu-west-1:222222222222:log-group:app:*", "arn region and account"},
+	{"ami-1", rewriteScope{}, map[string]string{"ami-1": "ami-2"}, "ami-2", "mapping"},
+}
+
+func TestRewriteValue(t *testing.T) {
+	for _, tc := range rewriteValueTcs {
+		v, reason := rewriteValue(tc.value, tc.scope, tc.mapping)
+		if v != tc.expected || reason != tc.reason {
+			t.Fatalf("Expected '%v' (%v) got '%v' (%v)", tc.expected, tc.reason, v, reason)
+		}
+	}
+}
+
+func TestRewriteParameters(t *testing.T) {
+	params := map[string]string{
+		"Topic":   "arn:aws:sns:us-east-1:111111111111:alerts",
+		"Subnets": "subnet-11111111,subnet-0123456789abcdef0",
+		"Kept":    "arn:aws:sns:us-east-1:111111111111:kept",
+		"Name":    "app",
+	}
+	mapping := map[string]string{"subnet-11111111": "subnet-22222222"}
+
+	rewritten, rewrites, warnings := rewriteParameters(params, map[string]bool{"Kept": true}, crossScope, mapping)
+
+	expected := map[string]string{
+		"Topic":   "arn:aws:sns:eu-west-1:222222222222:alerts",
+		"Subnets": "subnet-22222222,subnet-0123456789abcdef0",
+		"Kept":    "arn:aws:sns:us-east-1:111111111111:kept",
+		"Name":    "app",
+	}
+	if !reflect.DeepEqual(rewritten, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, rewritten)
+	}
+
+	expectedRewrites := []rewrite{
+		{Key: "Subnets", From: params["Subnets"], To: expected["Subnets"], Reason: "mapping"},
+		{Key: "Topic", From: params["Topic"], To: expected["Topic"], Reason: "arn region and account"},
+	}
+	if !reflect.DeepEqual(rewrites, expectedRewrites) {
+		t.Fatalf("Expected '%v' got '%v'", expectedRewrites, rewrites)
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning got '%v'", warnings)
+	}
+}
+
+var rewriteScopeTcs = []struct {
+	options  options
+	expected rewriteScope
+}{
+	{options{}, rewriteScope{"us-east-1", "us-east-1", "111111111111", "111111111111"}},
+	{options{TargetRegion: "eu-west-1", TargetAccountID: "222222222222"}, rewriteScope{"us-east-1", "eu-west-1", "111111111111", "222222222222"}},
+	{options{TargetRoleARN: "arn:aws:iam::333333333333:role/deploy"}, rewriteScope{"us-east-1", "us-east-1", "111111111111", "333333333333"}},
+	{options{TargetProfile: "other"}, rewriteScope{"us-east-1", "us-east-1", "111111111111", ""}},
+	{options{SourceRegion: "us-east-1"}, rewriteScope{"us-east-1", "ap-south-1", "111111111111", "111111111111"}},
+}
+
+func TestNewRewriteScope(t *testing.T) {
+	os.Setenv("AWS_REGION", "ap-south-1")
+	defer os.Unsetenv("AWS_REGION")
+
+	source := &Stack{StackId: "arn:aws:cloudformation:us-east-1:111111111111:stack/source/abc"}
+	for _, tc := range rewriteScopeTcs {
+		s := newRewriteScope(&tc.options, source)
+		if s != tc.expected {
+			t.Fatalf("Expected '%v' got '%v'", tc.expected, s)
+		}
+	}
+}
+
+func TestReadRewriteMap(t *testing.T) {
+	f, err := ioutil.TempFile("", "cfn-clone-rewrite-map")
+	if err != nil {
+		t.Fatalf("Unable to create temp file. %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("ami-1: ami-2\nsubnet-1: subnet-2\n")
+	f.Close()
+
+	mapping, err := readRewriteMap(f.Name())
+	expected := map[string]string{"ami-1": "ami-2", "subnet-1": "subnet-2"}
+	if err != nil || !reflect.DeepEqual(mapping, expected) {
+		t.Fatalf("Expected '%v' got '%v' (%v)", expected, mapping, err)
+	}
+
+	if _, err = readRewriteMap("/no-way-this-exists"); err == nil {
+		t.Fatalf("Expected an error for a missing rewrite map")
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// awsSdkClient implements CloudFormationClient by calling the CloudFormation
+// API directly through the aws sdk, so no aws cli is needed at runtime.
+type awsSdkClient struct {
+	cfn     *cloudformation.CloudFormation
+	ssm     *ssm.SSM
+	secrets *secretsmanager.SecretsManager
+	s3      *s3.S3
+	region  string
+}
+
+func newAwsSdkClient(config clientConfig) (*awsSdkClient, error) {
+	sess, err := newAwsSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only CloudFormation calls go to the endpoint URL, SSM, Secrets Manager,
+	// S3 and STS keep their own endpoints.
+	cfnConfig := &aws.Config{}
+	if config.EndpointURL != "" {
+		cfnConfig.Endpoint = aws.String(config.EndpointURL)
+	}
+
+	return &awsSdkClient{
+		cfn:     cloudformation.New(sess, cfnConfig),
+		ssm:     ssm.New(sess),
+		secrets: secretsmanager.New(sess),
+		s3:      s3.New(sess),
+		region:  aws.StringValue(sess.Config.Region),
+	}, nil
+}
+
+// newAwsSession builds a session honoring the same environment variables the
+// aws cli does, including AWS_DEFAULT_REGION, AWS_DEFAULT_PROFILE and the
+// legacy AWS_SECURITY_TOKEN. An explicit region or profile takes precedence,
+// and when a role is given its temporary credentials are used for every call.
+func newAwsSession(c clientConfig) (*session.Session, error) {
+	config := aws.Config{}
+
+	if c.Region != "" {
+		config.Region = aws.String(c.Region)
+	}
+
+	id := os.Getenv("AWS_ACCESS_KEY_ID")
+	secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	token := os.Getenv("AWS_SECURITY_TOKEN")
+	if c.Profile == "" && id != "" && secret != "" && token != "" && os.Getenv("AWS_SESSION_TOKEN") == "" {
+		config.Credentials = credentials.NewStaticCredentials(id, secret, token)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           c.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.RoleARN == "" {
+		return sess, nil
+	}
+
+	creds := stscreds.NewCredentials(sess, c.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = c.SessionName
+		if c.ExternalID != "" {
+			p.ExternalID = aws.String(c.ExternalID)
+		}
+	})
+
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+func (c *awsSdkClient) DescribeStack(name string) (*Stack, error) {
+	output, err := c.cfn.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output.Stacks) == 0 {
+		return nil, fmt.Errorf("Stack '%s' does not exist", name)
+	}
+
+	s := output.Stacks[0]
+	stack := &Stack{
+		StackId:            aws.StringValue(s.StackId),
+		StackName:          aws.StringValue(s.StackName),
+		StackStatus:        aws.StringValue(s.StackStatus),
+		StackStatusReason:  aws.StringValue(s.StackStatusReason),
+		Parameters:         map[string]string{},
+		Tags:               map[string]string{},
+		ResolvedParameters: map[string]string{},
+		StackSettings: StackSettings{
+			NotificationARNs:            aws.StringValueSlice(s.NotificationARNs),
+			RoleARN:                     aws.StringValue(s.RoleARN),
+			TimeoutInMinutes:            aws.Int64Value(s.TimeoutInMinutes),
+			EnableTerminationProtection: aws.BoolValue(s.EnableTerminationProtection),
+			RollbackConfiguration:       rollbackConfigurationFromSdk(s.RollbackConfiguration),
+		},
+	}
+	for _, p := range s.Parameters {
+		stack.Parameters[aws.StringValue(p.ParameterKey)] = aws.StringValue(p.ParameterValue)
+		if p.ResolvedValue != nil {
+			stack.ResolvedParameters[aws.StringValue(p.ParameterKey)] = aws.StringValue(p.ResolvedValue)
+		}
+	}
+	for _, t := range s.Tags {
+		stack.Tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	return stack, nil
+}
+
+func (c *awsSdkClient) GetTemplate(name string) (string, error) {
+	output, err := c.cfn.GetTemplate(&cloudformation.GetTemplateInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.TemplateBody), nil
+}
+
+func (c *awsSdkClient) GetStackPolicy(name string) (string, error) {
+	output, err := c.cfn.GetStackPolicy(&cloudformation.GetStackPolicyInput{
+		StackName: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.StackPolicyBody), nil
+}
+
+func (c *awsSdkClient) SetStackPolicy(name string, body string) error {
+	_, err := c.cfn.SetStackPolicy(&cloudformation.SetStackPolicyInput{
+		StackName:       aws.String(name),
+		StackPolicyBody: aws.String(body),
+	})
+	return err
+}
+
+func (c *awsSdkClient) UpdateTerminationProtection(name string, enable bool) error {
+	_, err := c.cfn.UpdateTerminationProtection(&cloudformation.UpdateTerminationProtectionInput{
+		StackName:                   aws.String(name),
+		EnableTerminationProtection: aws.Bool(enable),
+	})
+	return err
+}
+
+func (c *awsSdkClient) CreateStack(input *StackInput) (string, error) {
+	output, err := c.cfn.CreateStack(&cloudformation.CreateStackInput{
+		StackName:                   aws.String(input.StackName),
+		TemplateBody:                sdkTemplateBody(input),
+		TemplateURL:                 sdkTemplateURL(input),
+		Parameters:                  sdkParameters(input),
+		Capabilities:                aws.StringSlice(input.Capabilities),
+		Tags:                        sdkTags(input.Tags),
+		NotificationARNs:            aws.StringSlice(input.NotificationARNs),
+		RoleARN:                     sdkOptionalString(input.RoleARN),
+		StackPolicyBody:             sdkOptionalString(input.StackPolicyBody),
+		TimeoutInMinutes:            sdkOptionalInt64(input.TimeoutInMinutes),
+		EnableTerminationProtection: aws.Bool(input.EnableTerminationProtection),
+		RollbackConfiguration:       sdkRollbackConfiguration(input.RollbackConfiguration, false),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.StackId), nil
+}
+
+func (c *awsSdkClient) UpdateStack(input *StackInput) (string, error) {
+	output, err := c.cfn.UpdateStack(&cloudformation.UpdateStackInput{
+		StackName:             aws.String(input.StackName),
+		TemplateBody:          sdkTemplateBody(input),
+		TemplateURL:           sdkTemplateURL(input),
+		Parameters:            sdkParameters(input),
+		Capabilities:          aws.StringSlice(input.Capabilities),
+		Tags:                  sdkTags(input.Tags),
+		NotificationARNs:      aws.StringSlice(input.NotificationARNs),
+		RoleARN:               sdkOptionalString(input.RoleARN),
+		StackPolicyBody:       sdkOptionalString(input.StackPolicyBody),
+		RollbackConfiguration: sdkRollbackConfiguration(input.RollbackConfiguration, true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.StackId), nil
+}
+
+func (c *awsSdkClient) DeleteStack(name string) error {
+	_, err := c.cfn.DeleteStack(&cloudformation.DeleteStackInput{
+		StackName: aws.String(name),
+	})
+	return err
+}
+
+func (c *awsSdkClient) DescribeStackEvents(name string, seen map[string]bool) ([]StackEvent, error) {
+	events := []StackEvent{}
+
+	input := &cloudformation.DescribeStackEventsInput{StackName: aws.String(name)}
+	err := c.cfn.DescribeStackEventsPages(input, func(page *cloudformation.DescribeStackEventsOutput, last bool) bool {
+		start := len(events)
+		for _, e := range page.StackEvents {
+			events = append(events, StackEvent{
+				EventId:              aws.StringValue(e.EventId),
+				StackName:            aws.StringValue(e.StackName),
+				LogicalResourceId:    aws.StringValue(e.LogicalResourceId),
+				ResourceType:         aws.StringValue(e.ResourceType),
+				ResourceStatus:       aws.StringValue(e.ResourceStatus),
+				ResourceStatusReason: aws.StringValue(e.ResourceStatusReason),
+				Timestamp:            aws.TimeValue(e.Timestamp),
+			})
+		}
+		return !reachesSeen(events[start:], seen)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (c *awsSdkClient) CreateChangeSet(input *ChangeSetInput) (string, error) {
+	output, err := c.cfn.CreateChangeSet(&cloudformation.CreateChangeSetInput{
+		StackName:             aws.String(input.StackName),
+		TemplateBody:          sdkTemplateBody(&input.StackInput),
+		TemplateURL:           sdkTemplateURL(&input.StackInput),
+		Parameters:            sdkParameters(&input.StackInput),
+		Capabilities:          aws.StringSlice(input.Capabilities),
+		Tags:                  sdkTags(input.Tags),
+		NotificationARNs:      aws.StringSlice(input.NotificationARNs),
+		RoleARN:               sdkOptionalString(input.RoleARN),
+		RollbackConfiguration: sdkRollbackConfiguration(input.RollbackConfiguration, input.ChangeSetType == changeSetTypeUpdate),
+		ChangeSetName:         aws.String(input.ChangeSetName),
+		ChangeSetType:         aws.String(input.ChangeSetType),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.Id), nil
+}
+
+func (c *awsSdkClient) DescribeChangeSet(stack string, changeSet string) (*ChangeSet, error) {
+	input := &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stack),
+		ChangeSetName: aws.String(changeSet),
+	}
+
+	cs := &ChangeSet{}
+	for {
+		output, err := c.cfn.DescribeChangeSet(input)
+		if err != nil {
+			return nil, err
+		}
+
+		cs.ChangeSetId = aws.StringValue(output.ChangeSetId)
+		cs.StackId = aws.StringValue(output.StackId)
+		cs.ChangeSetName = aws.StringValue(output.ChangeSetName)
+		cs.Status = aws.StringValue(output.Status)
+		cs.StatusReason = aws.StringValue(output.StatusReason)
+		cs.ExecutionStatus = aws.StringValue(output.ExecutionStatus)
+
+		for _, ch := range output.Changes {
+			if ch.ResourceChange == nil {
+				continue
+			}
+			rc := ch.ResourceChange
+			cs.Changes = append(cs.Changes, ResourceChange{
+				Action:             aws.StringValue(rc.Action),
+				LogicalResourceId:  aws.StringValue(rc.LogicalResourceId),
+				PhysicalResourceId: aws.StringValue(rc.PhysicalResourceId),
+				ResourceType:       aws.StringValue(rc.ResourceType),
+				Replacement:        aws.StringValue(rc.Replacement),
+			})
+		}
+
+		if aws.StringValue(output.NextToken) == "" {
+			return cs, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (c *awsSdkClient) ExecuteChangeSet(stack string, changeSet string) error {
+	_, err := c.cfn.ExecuteChangeSet(&cloudformation.ExecuteChangeSetInput{
+		StackName:     aws.String(stack),
+		ChangeSetName: aws.String(changeSet),
+	})
+	return err
+}
+
+func (c *awsSdkClient) DeleteChangeSet(stack string, changeSet string) error {
+	_, err := c.cfn.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+		StackName:     aws.String(stack),
+		ChangeSetName: aws.String(changeSet),
+	})
+	return err
+}
+
+func sdkParameters(input *StackInput) []*cloudformation.Parameter {
+	params := input.parameterValues()
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	p := []*cloudformation.Parameter{}
+	for _, k := range keys {
+		p = append(p, &cloudformation.Parameter{
+			ParameterKey:   aws.String(k),
+			ParameterValue: aws.String(params[k]),
+		})
+	}
+	for _, k := range input.PreviousParameters {
+		p = append(p, &cloudformation.Parameter{
+			ParameterKey:     aws.String(k),
+			UsePreviousValue: aws.Bool(true),
+		})
+	}
+
+	return p
+}
+
+func sdkTags(tags map[string]string) []*cloudformation.Tag {
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	t := []*cloudformation.Tag{}
+	for _, k := range keys {
+		t = append(t, &cloudformation.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(tags[k]),
+		})
+	}
+
+	return t
+}
+
+// sdkOptionalString leaves empty values out of a request.
+func sdkOptionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return aws.String(v)
+}
+
+func sdkOptionalInt64(v int64) *int64 {
+	if v == 0 {
+		return nil
+	}
+	return aws.Int64(v)
+}
+
+// sdkRollbackConfiguration converts r for the sdk. An update without one
+// sends an empty configuration, clearing the stack's rollback triggers
+// rather than keeping them.
+func sdkRollbackConfiguration(r *RollbackConfiguration, update bool) *cloudformation.RollbackConfiguration {
+	if r == nil && update {
+		return &cloudformation.RollbackConfiguration{RollbackTriggers: []*cloudformation.RollbackTrigger{}}
+	}
+	if r == nil {
+		return nil
+	}
+
+	triggers := []*cloudformation.RollbackTrigger{}
+	for _, t := range r.RollbackTriggers {
+		triggers = append(triggers, &cloudformation.RollbackTrigger{
+			Arn:  aws.String(t.Arn),
+			Type: aws.String(t.Type),
+		})
+	}
+
+	return &cloudformation.RollbackConfiguration{
+		MonitoringTimeInMinutes: aws.Int64(r.MonitoringTimeInMinutes),
+		RollbackTriggers:        triggers,
+	}
+}
+
+func rollbackConfigurationFromSdk(r *cloudformation.RollbackConfiguration) *RollbackConfiguration {
+	if r == nil {
+		return nil
+	}
+
+	c := &RollbackConfiguration{MonitoringTimeInMinutes: aws.Int64Value(r.MonitoringTimeInMinutes)}
+	for _, t := range r.RollbackTriggers {
+		c.RollbackTriggers = append(c.RollbackTriggers, RollbackTrigger{
+			Arn:  aws.StringValue(t.Arn),
+			Type: aws.StringValue(t.Type),
+		})
+	}
+	return c
+}
+
+func (c *awsSdkClient) GetParameter(name string) (string, error) {
+	output, err := c.ssm.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+func (c *awsSdkClient) GetSecretValue(id string) (string, error) {
+	output, err := c.secrets.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(output.SecretString), nil
+}
+
+// sdkTemplateBody returns the template to pass inline, unless it was staged.
+func sdkTemplateBody(input *StackInput) *string {
+	if input.TemplateURL != "" {
+		return nil
+	}
+	return aws.String(input.TemplateBody)
+}
+
+func sdkTemplateURL(input *StackInput) *string {
+	if input.TemplateURL == "" {
+		return nil
+	}
+	return aws.String(input.TemplateURL)
+}
+
+func (c *awsSdkClient) PutTemplate(bucket string, key string, body string, kmsKeyID string) (string, error) {
+	put := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(body),
+	}
+	if kmsKeyID != "" {
+		put.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		put.SSEKMSKeyId = aws.String(kmsKeyID)
+	}
+
+	if _, err := c.s3.PutObject(put); err != nil {
+		return "", err
+	}
+
+	return templateURL(bucket, c.region, key), nil
+}
+
+func (c *awsSdkClient) DeleteTemplate(bucket string, key string) error {
+	_, err := c.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newStandInServer returns a server answering CloudFormation and STS query API
+// actions with canned XML bodies, recording the form of every request it
+// receives along with its Authorization header.
+func newStandInServer(t *testing.T, responses map[string]string, requests *[]url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Unable to parse request: %v", err)
+		}
+		r.PostForm.Set("Authorization", r.Header.Get("Authorization"))
+		*requests = append(*requests, r.PostForm)
+
+		action := r.PostForm.Get("Action")
+		body, ok := responses[action]
+		if !ok {
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `<ErrorResponse><Error><Code>ValidationError</Code><Message>unexpected %s</Message></Error></ErrorResponse>`, action)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%sResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/"><%sResult>%s</%sResult></%sResponse>`,
+			action, action, body, action, action)
+	}))
+}
+
+// awsTransport sends the requests meant for AWS endpoints to a stand-in
+// server instead, recording the host each was meant for. Requests already
+// going elsewhere, such as to --endpoint-url, are sent as they are.
+type awsTransport struct {
+	server *url.URL
+	hosts  []string
+	next   http.RoundTripper
+}
+
+func (a *awsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	a.hosts = append(a.hosts, r.URL.Host)
+	if strings.HasSuffix(r.URL.Hostname(), "amazonaws.com") {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = a.server.Scheme
+		r.URL.Host = a.server.Host
+		r.Host = ""
+	}
+	return a.next.RoundTrip(r)
+}
+
+// routeAwsTo sends requests for AWS endpoints to server until the returned
+// function is called.
+func routeAwsTo(t *testing.T, server *httptest.Server) (*awsTransport, func()) {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Unable to parse '%s': %v", server.URL, err)
+	}
+
+	next := http.DefaultClient.Transport
+	a := &awsTransport{server: u, next: next}
+	if a.next == nil {
+		a.next = http.DefaultTransport
+	}
+	http.DefaultClient.Transport = a
+	return a, func() { http.DefaultClient.Transport = next }
+}
+
+func newStandInClient(t *testing.T, server *httptest.Server) *awsSdkClient {
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	os.Unsetenv("AWS_CA_BUNDLE")
+
+	c, err := newAwsSdkClient(clientConfig{EndpointURL: server.URL})
+	if err != nil {
+		t.Fatalf("Unable to create sdk client: %v", err)
+	}
+
+	return c
+}
+
+func TestSdkDescribeStack(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"DescribeStacks": `<Stacks><member>
+			<StackId>arn:aws:cloudformation:us-east-1:123456789012:stack/foo/1</StackId>
+			<StackName>foo</StackName>
+			<StackStatus>CREATE_COMPLETE</StackStatus>
+			<Parameters>
+				<member><ParameterKey>Env</ParameterKey><ParameterValue>prod</ParameterValue></member>
+				<member><ParameterKey>Size</ParameterKey><ParameterValue>large</ParameterValue></member>
+				<member><ParameterKey>Image</ParameterKey><ParameterValue>/app/ami</ParameterValue><ResolvedValue>ami-1</ResolvedValue></member>
+			</Parameters>
+			<Tags>
+				<member><Key>Owner</Key><Value>me</Value></member>
+			</Tags>
+			<NotificationARNs><member>arn:aws:sns:us-east-1:123456789012:events</member></NotificationARNs>
+			<RoleARN>arn:aws:iam::123456789012:role/cfn</RoleARN>
+			<TimeoutInMinutes>30</TimeoutInMinutes>
+			<EnableTerminationProtection>true</EnableTerminationProtection>
+			<RollbackConfiguration>
+				<MonitoringTimeInMinutes>10</MonitoringTimeInMinutes>
+				<RollbackTriggers><member><Arn>arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors</Arn><Type>AWS::CloudWatch::Alarm</Type></member></RollbackTriggers>
+			</RollbackConfiguration>
+		</member></Stacks>`,
+	}, &requests)
+	defer server.Close()
+
+	stack, err := newStandInClient(t, server).DescribeStack("foo")
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := map[string]string{"Env": "prod", "Size": "large", "Image": "/app/ami"}
+	if stack.StackName != "foo" || !reflect.DeepEqual(stack.Parameters, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, stack.Parameters)
+	}
+
+	resolved := map[string]string{"Image": "ami-1"}
+	if !reflect.DeepEqual(stack.ResolvedParameters, resolved) {
+		t.Fatalf("Expected '%v' got '%v'", resolved, stack.ResolvedParameters)
+	}
+
+	if stack.Tags["Owner"] != "me" {
+		t.Fatalf("Expected tag Owner 'me' got '%v'", stack.Tags)
+	}
+
+	settings := StackSettings{
+		NotificationARNs:            []string{"arn:aws:sns:us-east-1:123456789012:events"},
+		RoleARN:                     "arn:aws:iam::123456789012:role/cfn",
+		TimeoutInMinutes:            30,
+		EnableTerminationProtection: true,
+		RollbackConfiguration: &RollbackConfiguration{
+			MonitoringTimeInMinutes: 10,
+			RollbackTriggers:        []RollbackTrigger{{Arn: "arn:aws:cloudwatch:us-east-1:123456789012:alarm:errors", Type: "AWS::CloudWatch::Alarm"}},
+		},
+	}
+	if !reflect.DeepEqual(stack.StackSettings, settings) {
+		t.Fatalf("Expected '%v' got '%v'", settings, stack.StackSettings)
+	}
+
+	if requests[0].Get("StackName") != "foo" {
+		t.Fatalf("Expected StackName 'foo' got '%s'", requests[0].Get("StackName"))
+	}
+}
+
+func TestSdkGetTemplate(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"GetTemplate": `<TemplateBody>{"Resources": {}}</TemplateBody>`,
+	}, &requests)
+	defer server.Close()
+
+	body, err := newStandInClient(t, server).GetTemplate("foo")
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if body != `{"Resources": {}}` {
+		t.Fatalf("Expected '%s' got '%s'", `{"Resources": {}}`, body)
+	}
+}
+
+func TestSdkDescribeStackEventsStopsAtSeenEvents(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"DescribeStackEvents": `<StackEvents>
+  <member><EventId>e2</EventId><StackName>foo</StackName><LogicalResourceId>foo</LogicalResourceId><ResourceStatus>UPDATE_IN_PROGRESS</ResourceStatus></member>
+  <member><EventId>e1</EventId><StackName>foo</StackName><LogicalResourceId>foo</LogicalResourceId><ResourceStatus>CREATE_COMPLETE</ResourceStatus></member>
+</StackEvents><NextToken>more</NextToken>`,
+	}, &requests)
+	defer server.Close()
+
+	events, err := newStandInClient(t, server).DescribeStackEvents("foo", map[string]bool{"e1": true})
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(events) != 2 || events[0].EventId != "e2" || events[0].ResourceStatus != "UPDATE_IN_PROGRESS" {
+		t.Fatalf("Expected the first page of events got '%v'", events)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("Expected paging to stop at a seen event got %d requests", len(requests))
+	}
+}
+
+func TestSdkCreateStack(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"CreateStack": `<StackId>arn:aws:cloudformation:us-east-1:123456789012:stack/bar/2</StackId>`,
+	}, &requests)
+	defer server.Close()
+
+	input := &StackInput{
+		StackName:    "bar",
+		TemplateBody: `{"Resources": {}}`,
+		Parameters:   map[string]string{"Size": "small", "Env": "dev"},
+		Capabilities: []string{"CAPABILITY_IAM"},
+		StackSettings: StackSettings{
+			NotificationARNs: []string{"arn:aws:sns:us-east-1:123456789012:events"},
+			StackPolicyBody:  `{"Statement":[]}`,
+			TimeoutInMinutes: 30,
+		},
+	}
+
+	id, err := newStandInClient(t, server).CreateStack(input)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if id != "arn:aws:cloudformation:us-east-1:123456789012:stack/bar/2" {
+		t.Fatalf("Unexpected stack id '%s'", id)
+	}
+
+	expected := map[string]string{
+		"StackName":                          "bar",
+		"TemplateBody":                       `{"Resources": {}}`,
+		"Capabilities.member.1":              "CAPABILITY_IAM",
+		"Parameters.member.1.ParameterKey":   "Env",
+		"Parameters.member.1.ParameterValue": "dev",
+		"Parameters.member.2.ParameterKey":   "Size",
+		"Parameters.member.2.ParameterValue": "small",
+		"NotificationARNs.member.1":          "arn:aws:sns:us-east-1:123456789012:events",
+		"StackPolicyBody":                    `{"Statement":[]}`,
+		"TimeoutInMinutes":                   "30",
+	}
+	for k, v := range expected {
+		if requests[0].Get(k) != v {
+			t.Fatalf("Expected %s to be '%s' got '%s'", k, v, requests[0].Get(k))
+		}
+	}
+
+	input.TemplateURL = "https://bucket.s3.amazonaws.com/bar.json"
+	if _, err = newStandInClient(t, server).CreateStack(input); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if requests[1].Get("TemplateURL") != input.TemplateURL || requests[1]["TemplateBody"] != nil {
+		t.Fatalf("Expected only TemplateURL to be sent got '%v'", requests[1])
+	}
+
+	input.PreviousParameters = []string{"Size"}
+	if _, err = newStandInClient(t, server).CreateStack(input); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if requests[2].Get("Parameters.member.2.ParameterKey") != "Size" || requests[2].Get("Parameters.member.2.UsePreviousValue") != "true" || requests[2]["Parameters.member.2.ParameterValue"] != nil {
+		t.Fatalf("Expected Size to use its previous value got '%v'", requests[2])
+	}
+}
+
+func TestSdkCreateChangeSetClearsDroppedSettings(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"CreateChangeSet": `<Id>arn:aws:cloudformation:us-east-1:123456789012:changeSet/cs/1</Id>`,
+	}, &requests)
+	defer server.Close()
+
+	input := &ChangeSetInput{
+		StackInput:    StackInput{StackName: "bar", TemplateBody: `{"Resources": {}}`},
+		ChangeSetName: "cs",
+		ChangeSetType: "UPDATE",
+	}
+
+	c := newStandInClient(t, server)
+	if _, err := c.CreateChangeSet(input); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	for _, k := range []string{"NotificationARNs", "RollbackConfiguration.RollbackTriggers"} {
+		if v, ok := requests[0][k]; !ok || v[0] != "" {
+			t.Fatalf("Expected %s to be sent empty got '%v'", k, requests[0])
+		}
+	}
+
+	input.ChangeSetType = "CREATE"
+	if _, err := c.CreateChangeSet(input); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if _, ok := requests[1]["RollbackConfiguration.RollbackTriggers"]; ok {
+		t.Fatalf("Expected no rollback configuration on create got '%v'", requests[1])
+	}
+}
+
+func TestSdkGetStackPolicy(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"GetStackPolicy": `<StackPolicyBody>{"Statement": []}</StackPolicyBody>`,
+	}, &requests)
+	defer server.Close()
+
+	body, err := newStandInClient(t, server).GetStackPolicy("foo")
+	if err != nil || body != `{"Statement": []}` {
+		t.Fatalf("Expected '%s' got '%s' with '%v'", `{"Statement": []}`, body, err)
+	}
+}
+
+func TestSdkErrorResponse(t *testing.T) {
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{}, &requests)
+	defer server.Close()
+
+	if _, err := newStandInClient(t, server).DescribeStack("foo"); err == nil {
+		t.Fatalf("Expected error from stand-in server")
+	}
+}
+
+func TestSdkAssumesRole(t *testing.T) {
+	stsRequests := []url.Values{}
+	sts := newStandInServer(t, map[string]string{
+		"AssumeRole": `<Credentials>
+			<AccessKeyId>ASIAASSUMED</AccessKeyId>
+			<SecretAccessKey>assumed-secret</SecretAccessKey>
+			<SessionToken>assumed-token</SessionToken>
+			<Expiration>2099-01-01T00:00:00Z</Expiration>
+		</Credentials>`,
+	}, &stsRequests)
+	defer sts.Close()
+
+	requests := []url.Values{}
+	server := newStandInServer(t, map[string]string{
+		"GetTemplate": `<TemplateBody>{}</TemplateBody>`,
+	}, &requests)
+	defer server.Close()
+
+	transport, restore := routeAwsTo(t, sts)
+	defer restore()
+
+	newStandInClient(t, server)
+	c, err := newAwsSdkClient(clientConfig{
+		EndpointURL: server.URL,
+		RoleARN:     "arn:aws:iam::210987654321:role/cloner",
+		ExternalID:  "ext-id",
+		SessionName: "cfn-clone",
+	})
+	if err != nil {
+		t.Fatalf("Unable to create sdk client: %v", err)
+	}
+
+	if _, err = c.GetTemplate("foo"); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(requests) != 1 || requests[0].Get("Action") != "GetTemplate" {
+		t.Fatalf("Expected only GetTemplate at the endpoint URL got '%v'", requests)
+	}
+
+	if len(stsRequests) != 1 || !strings.HasPrefix(transport.hosts[0], "sts.") {
+		t.Fatalf("Expected AssumeRole to go to STS got '%v' at '%v'", stsRequests, transport.hosts)
+	}
+
+	for k, v := range map[string]string{"RoleArn": "arn:aws:iam::210987654321:role/cloner", "ExternalId": "ext-id", "RoleSessionName": "cfn-clone"} {
+		if stsRequests[0].Get(k) != v {
+			t.Fatalf("Expected %s to be '%s' got '%s'", k, v, stsRequests[0].Get(k))
+		}
+	}
+
+	if !strings.Contains(requests[0].Get("Authorization"), "Credential=ASIAASSUMED/") {
+		t.Fatalf("Expected GetTemplate to be signed with the assumed credentials got '%s'", requests[0].Get("Authorization"))
+	}
+}
+
+// newJSONStandInServer answers JSON protocol API calls, such as those of SSM
+// and Secrets Manager, with canned bodies keyed by X-Amz-Target.
+func newJSONStandInServer(t *testing.T, responses map[string]string, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Unable to read request: %v", err)
+		}
+		*requests = append(*requests, string(body))
+
+		target := r.Header.Get("X-Amz-Target")
+		response, ok := responses[target]
+		if !ok {
+			w.WriteHeader(400)
+			fmt.Fprintf(w, `{"__type": "ValidationException", "message": "unexpected %s"}`, target)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprint(w, response)
+	}))
+}
+
+func TestSdkGetParameter(t *testing.T) {
+	requests := []string{}
+	server := newJSONStandInServer(t, map[string]string{
+		"AmazonSSM.GetParameter": `{"Parameter": {"Name": "/app/db/pass", "Value": "hunter2"}}`,
+	}, &requests)
+	defer server.Close()
+
+	transport, restore := routeAwsTo(t, server)
+	defer restore()
+
+	v, err := newStandInClient(t, server).GetParameter("/app/db/pass")
+	if err != nil || v != "hunter2" {
+		t.Fatalf("Expected 'hunter2' got '%v' (%v)", v, err)
+	}
+
+	if transport.hosts[0] != "ssm.us-east-1.amazonaws.com" {
+		t.Fatalf("Expected the call to go to SSM got '%v'", transport.hosts)
+	}
+
+	if !strings.Contains(requests[0], `"WithDecryption":true`) {
+		t.Fatalf("Expected decryption to be requested got '%v'", requests[0])
+	}
+}
+
+func TestSdkGetSecretValue(t *testing.T) {
+	requests := []string{}
+	server := newJSONStandInServer(t, map[string]string{
+		"secretsmanager.GetSecretValue": `{"Name": "db", "SecretString": "{\"password\": \"hunter2\"}"}`,
+	}, &requests)
+	defer server.Close()
+
+	transport, restore := routeAwsTo(t, server)
+	defer restore()
+
+	v, err := newStandInClient(t, server).GetSecretValue("db")
+	if err != nil || v != `{"password": "hunter2"}` {
+		t.Fatalf("Expected secret got '%v' (%v)", v, err)
+	}
+
+	if transport.hosts[0] != "secretsmanager.us-east-1.amazonaws.com" {
+		t.Fatalf("Expected the call to go to Secrets Manager got '%v'", transport.hosts)
+	}
+}
+
+func TestSdkPutTemplate(t *testing.T) {
+	requests := []*http.Request{}
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	transport, restore := routeAwsTo(t, server)
+	defer restore()
+
+	c := newStandInClient(t, server)
+	u, err := c.PutTemplate("bucket", "t/clone.json", `{"Resources": {}}`, "alias/templates")
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if u != "https://bucket.s3.amazonaws.com/t/clone.json" {
+		t.Fatalf("Expected 'https://bucket.s3.amazonaws.com/t/clone.json' got '%v'", u)
+	}
+
+	r := requests[0]
+	if transport.hosts[0] != "bucket.s3.amazonaws.com" {
+		t.Fatalf("Expected the template to go to S3 got '%v'", transport.hosts)
+	}
+
+	if r.Method != "PUT" || r.URL.Path != "/t/clone.json" || bodies[0] != `{"Resources": {}}` || r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "alias/templates" {
+		t.Fatalf("Unexpected put request %s %s '%s' %v", r.Method, r.URL.Path, bodies[0], r.Header)
+	}
+
+	if err = c.DeleteTemplate("bucket", "t/clone.json"); err != nil || requests[1].Method != "DELETE" {
+		t.Fatalf("Expected a delete request got '%v'", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeSettings carries the source stack's settings over to the clone,
+// applying the per setting override and drop flags. ARNs copied from the
+// source are rewritten for the target like parameter values, and returned
+// with the settings keyed "Settings." and the setting name.
+func mergeSettings(source StackSettings, options *options, scope rewriteScope, mapping map[string]string) (StackSettings, []rewrite, error) {
+	s := StackSettings{
+		StackPolicyBody:             source.StackPolicyBody,
+		TimeoutInMinutes:            source.TimeoutInMinutes,
+		EnableTerminationProtection: source.EnableTerminationProtection,
+	}
+
+	rewrites := []rewrite{}
+	rewriteSetting := func(key string, v string) string {
+		to, reason := rewriteValue(v, scope, mapping)
+		if reason != "" {
+			rewrites = append(rewrites, rewrite{Key: "Settings." + key, From: v, To: to, Reason: reason})
+		}
+		return to
+	}
+
+	switch {
+	case options.NoNotifications:
+	case len(options.NotificationARNs) > 0:
+		s.NotificationARNs = options.NotificationARNs
+	default:
+		for _, a := range source.NotificationARNs {
+			s.NotificationARNs = append(s.NotificationARNs, rewriteSetting("NotificationARNs", a))
+		}
+	}
+
+	switch {
+	case options.NoStackRole:
+	case options.StackRoleARN != "":
+		s.RoleARN = options.StackRoleARN
+	default:
+		s.RoleARN = rewriteSetting("RoleARN", source.RoleARN)
+	}
+
+	switch {
+	case options.NoStackPolicy:
+		s.StackPolicyBody = ""
+	case options.StackPolicyFile != "":
+		body, err := readStackPolicy(options.StackPolicyFile)
+		if err != nil {
+			return s, nil, err
+		}
+		s.StackPolicyBody = body
+	}
+
+	switch {
+	case options.NoTimeout:
+		s.TimeoutInMinutes = 0
+	case options.TimeoutInMinutes > 0:
+		s.TimeoutInMinutes = options.TimeoutInMinutes
+	}
+
+	switch {
+	case options.NoTerminationProtection:
+		s.EnableTerminationProtection = false
+	case options.TerminationProtection:
+		s.EnableTerminationProtection = true
+	}
+
+	switch {
+	case options.NoRollbackConfiguration:
+	case options.RollbackConfigurationFile != "":
+		r, err := readRollbackConfiguration(options.RollbackConfigurationFile)
+		if err != nil {
+			return s, nil, err
+		}
+		s.RollbackConfiguration = r
+	default:
+		if r := source.RollbackConfiguration; r != nil && len(r.RollbackTriggers) > 0 {
+			s.RollbackConfiguration = &RollbackConfiguration{MonitoringTimeInMinutes: r.MonitoringTimeInMinutes}
+			for _, t := range r.RollbackTriggers {
+				t.Arn = rewriteSetting("RollbackTriggers", t.Arn)
+				s.RollbackConfiguration.RollbackTriggers = append(s.RollbackConfiguration.RollbackTriggers, t)
+			}
+		}
+	}
+
+	return s, rewrites, nil
+}
+
+// settingsValues describes the set stack settings for display, keyed by
+// setting name.
+func settingsValues(s StackSettings) map[string]string {
+	values := map[string]string{}
+
+	if len(s.NotificationARNs) > 0 {
+		values["NotificationARNs"] = strings.Join(s.NotificationARNs, ", ")
+	}
+
+	if s.RoleARN != "" {
+		values["RoleARN"] = s.RoleARN
+	}
+
+	if s.StackPolicyBody != "" {
+		var b bytes.Buffer
+		if err := json.Compact(&b, []byte(s.StackPolicyBody)); err != nil {
+			b.WriteString(s.StackPolicyBody)
+		}
+		values["StackPolicy"] = b.String()
+	}
+
+	if s.TimeoutInMinutes > 0 {
+		values["TimeoutInMinutes"] = strconv.FormatInt(s.TimeoutInMinutes, 10)
+	}
+
+	if s.EnableTerminationProtection {
+		values["TerminationProtection"] = "enabled"
+	}
+
+	if r := s.RollbackConfiguration; r != nil {
+		arns := []string{}
+		for _, t := range r.RollbackTriggers {
+			arns = append(arns, t.Arn)
+		}
+		values["RollbackTriggers"] = fmt.Sprintf("%s, monitored for %d minutes", strings.Join(arns, ", "), r.MonitoringTimeInMinutes)
+	}
+
+	return values
+}
+
+func prettySettings(s StackSettings) string {
+	return prettyValues("The stack settings are:", settingsValues(s))
+}
+
+// readStackPolicy reads a stack policy, which CloudFormation only accepts as
+// JSON.
+func readStackPolicy(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Unable to read stack policy '%s'. %s", path, err.Error())
+	}
+
+	if !json.Valid(data) {
+		return "", fmt.Errorf("Malformed stack policy '%s', expected a JSON document", path)
+	}
+
+	return string(data), nil
+}
+
+// readRollbackConfiguration reads a rollback configuration in the aws cli
+// format, {"RollbackTriggers": [{"Arn": "...", "Type": "AWS::CloudWatch::Alarm"}],
+// "MonitoringTimeInMinutes": 10}, written as JSON or YAML.
+func readRollbackConfiguration(path string) (*RollbackConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read rollback configuration '%s'. %s", path, err.Error())
+	}
+
+	var doc interface{}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Malformed rollback configuration '%s'. %s", path, err.Error())
+	}
+
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed rollback configuration '%s'. %s", path, err.Error())
+	}
+
+	r := &RollbackConfiguration{}
+	if err = json.Unmarshal(j, r); err != nil {
+		return nil, fmt.Errorf("Malformed rollback configuration '%s'. %s", path, err.Error())
+	}
+
+	for _, t := range r.RollbackTriggers {
+		if t.Arn == "" || t.Type == "" {
+			return nil, fmt.Errorf("Malformed rollback configuration '%s', every trigger needs an Arn and a Type", path)
+		}
+	}
+
+	return r, nil
+}
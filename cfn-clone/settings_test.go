@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func testSourceSettings() StackSettings {
+	return StackSettings{
+		NotificationARNs:            []string{"arn:aws:sns:us-east-1:111111111111:events"},
+		RoleARN:                     "arn:aws:iam::111111111111:role/cfn",
+		StackPolicyBody:             `{"Statement": []}`,
+		TimeoutInMinutes:            30,
+		EnableTerminationProtection: true,
+		RollbackConfiguration: &RollbackConfiguration{
+			MonitoringTimeInMinutes: 10,
+			RollbackTriggers:        []RollbackTrigger{{Arn: "arn:aws:cloudwatch:us-east-1:111111111111:alarm:errors", Type: "AWS::CloudWatch::Alarm"}},
+		},
+	}
+}
+
+func TestMergeSettingsCopiesSource(t *testing.T) {
+	source := testSourceSettings()
+
+	s, _, err := mergeSettings(source, &options{}, rewriteScope{}, map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !reflect.DeepEqual(s, source) {
+		t.Fatalf("Expected '%v' got '%v'", source, s)
+	}
+}
+
+func TestMergeSettingsOverridesAndDrops(t *testing.T) {
+	policy, _ := ioutil.TempFile("", "policy")
+	defer os.Remove(policy.Name())
+	policy.WriteString(`{"Statement": [{"Effect": "Deny"}]}`)
+	policy.Close()
+
+	opts := &options{
+		NotificationARNs:        []string{"arn:aws:sns:us-east-1:111111111111:other"},
+		NoStackRole:             true,
+		StackPolicyFile:         policy.Name(),
+		NoTimeout:               true,
+		NoTerminationProtection: true,
+		NoRollbackConfiguration: true,
+	}
+
+	s, _, err := mergeSettings(testSourceSettings(), opts, rewriteScope{}, map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := StackSettings{
+		NotificationARNs: opts.NotificationARNs,
+		StackPolicyBody:  `{"Statement": [{"Effect": "Deny"}]}`,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, s)
+	}
+}
+
+func TestMergeSettingsRewritesArns(t *testing.T) {
+	scope := rewriteScope{SourceRegion: "us-east-1", TargetRegion: "us-west-2", SourceAccount: "111111111111", TargetAccount: "222222222222"}
+
+	s, rewrites, err := mergeSettings(testSourceSettings(), &options{}, scope, map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	expected := []string{
+		"arn:aws:sns:us-west-2:222222222222:events",
+		"arn:aws:iam::222222222222:role/cfn",
+		"arn:aws:cloudwatch:us-west-2:222222222222:alarm:errors",
+	}
+	result := []string{s.NotificationARNs[0], s.RoleARN, s.RollbackConfiguration.RollbackTriggers[0].Arn}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+
+	expectedRewrites := []rewrite{
+		{Key: "Settings.NotificationARNs", From: "arn:aws:sns:us-east-1:111111111111:events", To: expected[0], Reason: "arn region and account"},
+		{Key: "Settings.RoleARN", From: "arn:aws:iam::111111111111:role/cfn", To: expected[1], Reason: "arn account"},
+		{Key: "Settings.RollbackTriggers", From: "arn:aws:cloudwatch:us-east-1:111111111111:alarm:errors", To: expected[2], Reason: "arn region and account"},
+	}
+	if !reflect.DeepEqual(rewrites, expectedRewrites) {
+		t.Fatalf("Expected '%v' got '%v'", expectedRewrites, rewrites)
+	}
+
+	opts := &options{NoNotifications: true, StackRoleARN: "arn:aws:iam::222222222222:role/other", NoRollbackConfiguration: true}
+	if _, rewrites, _ = mergeSettings(testSourceSettings(), opts, scope, map[string]string{}); len(rewrites) != 0 {
+		t.Fatalf("Expected no rewrites of overridden settings got '%v'", rewrites)
+	}
+}
+
+func TestMergeSettingsDropsEmptyRollbackConfiguration(t *testing.T) {
+	source := StackSettings{RollbackConfiguration: &RollbackConfiguration{}}
+
+	s, _, _ := mergeSettings(source, &options{}, rewriteScope{}, map[string]string{})
+	if s.RollbackConfiguration != nil {
+		t.Fatalf("Expected no rollback configuration got '%v'", s.RollbackConfiguration)
+	}
+}
+
+func TestSettingsValues(t *testing.T) {
+	expected := map[string]string{
+		"NotificationARNs":      "arn:aws:sns:us-east-1:111111111111:events",
+		"RoleARN":               "arn:aws:iam::111111111111:role/cfn",
+		"StackPolicy":           `{"Statement":[]}`,
+		"TimeoutInMinutes":      "30",
+		"TerminationProtection": "enabled",
+		"RollbackTriggers":      "arn:aws:cloudwatch:us-east-1:111111111111:alarm:errors, monitored for 10 minutes",
+	}
+
+	if result := settingsValues(testSourceSettings()); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+
+	if result := settingsValues(StackSettings{}); len(result) != 0 {
+		t.Fatalf("Expected no values got '%v'", result)
+	}
+}
+
+var rollbackConfigurationTcs = []struct {
+	content       string
+	expected      *RollbackConfiguration
+	resultIsError bool
+}{
+	{
+		`{"RollbackTriggers": [{"Arn": "arn:a", "Type": "AWS::CloudWatch::Alarm"}], "MonitoringTimeInMinutes": 5}`,
+		&RollbackConfiguration{MonitoringTimeInMinutes: 5, RollbackTriggers: []RollbackTrigger{{Arn: "arn:a", Type: "AWS::CloudWatch::Alarm"}}},
+		false,
+	},
+	{
+		"RollbackTriggers:\n  - Arn: arn:a\n    Type: AWS::CloudWatch::Alarm\n",
+		&RollbackConfiguration{RollbackTriggers: []RollbackTrigger{{Arn: "arn:a", Type: "AWS::CloudWatch::Alarm"}}},
+		false,
+	},
+	{`{"RollbackTriggers": [{"Arn": "arn:a"}]}`, nil, true},
+	{`[1, 2]`, nil, true},
+}
+
+func TestReadRollbackConfiguration(t *testing.T) {
+	for _, tc := range rollbackConfigurationTcs {
+		f, _ := ioutil.TempFile("", "rollback")
+		f.WriteString(tc.content)
+		f.Close()
+
+		result, err := readRollbackConfiguration(f.Name())
+		os.Remove(f.Name())
+
+		if (err != nil) != tc.resultIsError || !reflect.DeepEqual(result, tc.expected) {
+			t.Fatalf("Expected '%v' got '%v' with '%v' for '%s'", tc.expected, result, err, tc.content)
+		}
+	}
+}
+
+func TestReadStackPolicy(t *testing.T) {
+	f, _ := ioutil.TempFile("", "policy")
+	defer os.Remove(f.Name())
+	f.WriteString("Statement: []")
+	f.Close()
+
+	if _, err := readStackPolicy(f.Name()); err == nil {
+		t.Fatalf("Expected an error for a YAML stack policy")
+	}
+}
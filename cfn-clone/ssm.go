@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const ssmValueTypePrefix = "AWS::SSM::Parameter::Value<"
+
+// ssmParameter is a parameter whose value is the name of an SSM parameter
+// which CloudFormation resolves on every deploy.
+type ssmParameter struct {
+	Key      string
+	Name     string
+	Resolved string
+	Pinned   bool
+}
+
+// ssmValueType returns the type an AWS::SSM::Parameter::Value<...> parameter
+// resolves to, for use once its value is pinned.
+func ssmValueType(t string) (string, bool) {
+	if !strings.HasPrefix(t, ssmValueTypePrefix) || !strings.HasSuffix(t, ">") {
+		return "", false
+	}
+
+	inner := strings.TrimSuffix(strings.TrimPrefix(t, ssmValueTypePrefix), ">")
+	if inner == "List<String>" {
+		return "CommaDelimitedList", true
+	}
+	return inner, true
+}
+
+// ssmParameters returns the parameters the template resolves from SSM, along
+// with the values they resolved to in the source stack. Keys in skip were
+// overridden and are left out.
+func ssmParameters(t *cfnTemplate, params map[string]string, resolved map[string]string, skip map[string]bool) []ssmParameter {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	found := []ssmParameter{}
+	for _, k := range keys {
+		p, ok := t.Parameters[k]
+		if !ok || skip[k] {
+			continue
+		}
+		if _, ok := ssmValueType(p.Type); !ok {
+			continue
+		}
+		v, ok := resolved[k]
+		if !ok {
+			continue
+		}
+		found = append(found, ssmParameter{Key: k, Name: params[k], Resolved: v})
+	}
+
+	return found
+}
+
+// pinSSMParameters freezes the ssm parameters at their resolved values,
+// changing their types in the template body to the underlying types so
+// CloudFormation no longer looks them up.
+func pinSSMParameters(body string, t *cfnTemplate, params map[string]string, ssm []ssmParameter) (string, error) {
+	if len(ssm) == 0 {
+		return body, nil
+	}
+
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		return "", fmt.Errorf("Unable to pin SSM parameters. %s", err.Error())
+	}
+
+	declared := mappingValue(b.root(), "Parameters")
+	for i, s := range ssm {
+		typ := mappingValue(mappingValue(declared, s.Key), "Type")
+		if typ == nil {
+			return "", fmt.Errorf("Unable to pin SSM parameter '%s', the template does not declare its type", s.Key)
+		}
+
+		p := t.Parameters[s.Key]
+		p.Type, _ = ssmValueType(p.Type)
+		t.Parameters[s.Key] = p
+
+		setString(typ, p.Type)
+		params[s.Key] = s.Resolved
+		ssm[i].Pinned = true
+	}
+
+	pinned, err := b.String()
+	if err != nil {
+		return "", fmt.Errorf("Unable to pin SSM parameters. %s", err.Error())
+	}
+
+	return pinned, nil
+}
+
+// ssmDisplayValues masks params for display and notes, for every SSM
+// parameter, the value it resolved to or the name it was pinned from.
+func ssmDisplayValues(redactor *redactor, params map[string]string, ssm []ssmParameter) map[string]string {
+	values := redactor.parameters(params)
+	for _, s := range ssm {
+		if s.Pinned {
+			values[s.Key] = fmt.Sprintf("%s (pinned from '%s')", redactor.value(s.Key, s.Resolved), s.Name)
+		} else {
+			values[s.Key] = fmt.Sprintf("%s (resolves to '%s')", s.Name, redactor.value(s.Key, s.Resolved))
+		}
+	}
+	return values
+}
+
+// redactSSMParameters masks the resolved values of secret ssm parameters.
+func redactSSMParameters(redactor *redactor, ssm []ssmParameter) []ssmParameter {
+	redacted := []ssmParameter{}
+	for _, s := range ssm {
+		s.Resolved = redactor.value(s.Key, s.Resolved)
+		redacted = append(redacted, s)
+	}
+	return redacted
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var ssmValueTypeTcs = []struct {
+	in       string
+	expected string
+	ok       bool
+}{
+	{"String", "", false},
+	{"AWS::SSM::Parameter::Name", "", false},
+	{"AWS::SSM::Parameter::Value<String>", "String", true},
+	{"AWS::SSM::Parameter::Value<List<String>>", "CommaDelimitedList", true},
+	{"AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>", "AWS::EC2::Image::Id", true},
+	{"AWS::SSM::Parameter::Value<List<AWS::EC2::Subnet::Id>>", "List<AWS::EC2::Subnet::Id>", true},
+}
+
+func TestSSMValueType(t *testing.T) {
+	for _, tc := range ssmValueTypeTcs {
+		v, ok := ssmValueType(tc.in)
+		if v != tc.expected || ok != tc.ok {
+			t.Fatalf("Expected '%v' (%v) got '%v' (%v)", tc.expected, tc.ok, v, ok)
+		}
+	}
+}
+
+const ssmTemplate = `{"Parameters": {
+	"Image": {"Type": "AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>"},
+	"Subnets": {"Type": "AWS::SSM::Parameter::Value<List<String>>"},
+	"Name": {"Type": "String"}
+}, "Resources": {}}`
+
+func TestSSMParameters(t *testing.T) {
+	tmpl, err := parseTemplate(ssmTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	params := map[string]string{"Image": "/app/ami", "Subnets": "/app/subnets", "Name": "app"}
+	resolved := map[string]string{"Image": "ami-1", "Subnets": "subnet-1,subnet-2"}
+
+	found := ssmParameters(tmpl, params, resolved, map[string]bool{"Subnets": true})
+	expected := []ssmParameter{{Key: "Image", Name: "/app/ami", Resolved: "ami-1"}}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, found)
+	}
+}
+
+func TestPinSSMParameters(t *testing.T) {
+	tmpl, err := parseTemplate(ssmTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	params := map[string]string{"Image": "/app/ami", "Subnets": "/app/subnets", "Name": "app"}
+	ssm := ssmParameters(tmpl, params, map[string]string{"Image": "ami-1", "Subnets": "subnet-1,subnet-2"}, nil)
+
+	body, err := pinSSMParameters(ssmTemplate, tmpl, params, ssm)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	pinned, err := parseTemplate(body)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if pinned.Parameters["Image"].Type != "AWS::EC2::Image::Id" || pinned.Parameters["Subnets"].Type != "CommaDelimitedList" || pinned.Parameters["Name"].Type != "String" {
+		t.Fatalf("Expected pinned types got '%v'", pinned.Parameters)
+	}
+
+	if tmpl.Parameters["Image"].Type != "AWS::EC2::Image::Id" {
+		t.Fatalf("Expected 'AWS::EC2::Image::Id' got '%v'", tmpl.Parameters["Image"].Type)
+	}
+
+	expected := map[string]string{"Image": "ami-1", "Subnets": "subnet-1,subnet-2", "Name": "app"}
+	if !reflect.DeepEqual(params, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, params)
+	}
+
+	if !ssm[0].Pinned || !ssm[1].Pinned {
+		t.Fatalf("Expected parameters to be marked pinned got '%v'", ssm)
+	}
+}
@@ -4,185 +4,649 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type describeStackResponse struct {
 	Stacks []struct {
-		Parameters []struct {
+		StackId           string
+		StackName         string
+		StackStatus       string
+		StackStatusReason string
+		Parameters        []struct {
 			ParameterKey   string
 			ParameterValue string
+			ResolvedValue  string
 		}
+		Tags []struct {
+			Key   string
+			Value string
+		}
+		NotificationARNs            []string
+		RoleARN                     string
+		TimeoutInMinutes            int64
+		EnableTerminationProtection bool
+		RollbackConfiguration       *RollbackConfiguration
 	}
 }
 
-func createStackCmd(name string, params map[string]string, template string) ([]string, error) {
-	cmd := []string{
-		"aws",
-		"cloudformation",
-		"create-stack",
-		"--output",
-		"json",
-		"--stack-name",
-		name,
-		"--template-body",
-		"file:///" + template,
-		"--capabilities", "CAPABILITY_IAM",
-		"--parameters",
+// getTemplateResponse is the output of get-template. The aws cli returns the
+// body of YAML templates as a string, but decodes JSON templates into an
+// object, which is kept as written.
+type getTemplateResponse struct {
+	TemplateBody json.RawMessage
+}
+
+func (r getTemplateResponse) template() (string, error) {
+	if len(r.TemplateBody) > 0 && r.TemplateBody[0] == '"' {
+		var body string
+		err := json.Unmarshal(r.TemplateBody, &body)
+		return body, err
 	}
+	return string(r.TemplateBody), nil
+}
+
+type getStackPolicyResponse struct {
+	StackPolicyBody string
+}
 
-	if err := noEchoParamsOverriden(params); err != nil {
-		return []string{}, err
+type getParameterResponse struct {
+	Parameter struct {
+		Value string
 	}
+}
 
-	return append(cmd, cliParamsForCreate(params)...), nil
+type getSecretValueResponse struct {
+	SecretString string
 }
 
-func createStack(name string, params map[string]string, template string) (string, error) {
-	createCmd, err := createStackCmd(name, params, template)
-	if err != nil {
-		return "", err
+type assumeRoleResponse struct {
+	Credentials assumedCredentials
+}
+
+// assumedCredentials are the temporary credentials of an assumed role.
+type assumedCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// stackEventsPageSize is how many events describe-stack-events returns at a
+// time.
+const stackEventsPageSize = "100"
+
+type describeStackEventsResponse struct {
+	StackEvents []StackEvent
+	NextToken   string
+}
+
+type describeChangeSetResponse struct {
+	ChangeSetId     string
+	StackId         string
+	ChangeSetName   string
+	Status          string
+	StatusReason    string
+	ExecutionStatus string
+	Changes         []struct {
+		ResourceChange ResourceChange
+	}
+}
+
+// awsCliClient implements CloudFormationClient by invoking the aws cli.
+type awsCliClient struct {
+	config      clientConfig
+	out         io.Writer
+	credentials *assumedCredentials
+}
+
+func newAwsCliClient(config clientConfig, out io.Writer) *awsCliClient {
+	return &awsCliClient{config: config, out: out}
+}
+
+// globalArgs returns the aws cli options selecting the endpoint, region and
+// profile of the client for a command of service. Only cloudformation
+// commands go to the endpoint URL. Once a role is assumed its credentials
+// are used instead of the profile.
+func (c *awsCliClient) globalArgs(service string) []string {
+	args := []string{}
+
+	if c.config.EndpointURL != "" && service == "cloudformation" {
+		args = append(args, "--endpoint-url", c.config.EndpointURL)
+	}
+
+	if c.config.Region != "" {
+		args = append(args, "--region", c.config.Region)
 	}
 
-	fmt.Println("Going to run with command:")
-	fmt.Printf("%s\n", strings.Join(createCmd, " "))
+	if c.config.Profile != "" && c.credentials == nil {
+		args = append(args, "--profile", c.config.Profile)
+	}
+
+	return args
+}
+
+// env returns the environment for aws cli invocations. When a role is
+// configured its temporary credentials are passed through the environment,
+// keeping them off the command line.
+func (c *awsCliClient) env() ([]string, error) {
+	if c.config.RoleARN == "" {
+		return nil, nil
+	}
+
+	if c.credentials == nil || time.Now().Add(5*time.Minute).After(c.credentials.Expiration) {
+		c.credentials = nil
+
+		output, err := execAws(append(assumeRoleCmd(c.config), c.globalArgs("sts")...), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to assume role '%s'. %s", c.config.RoleARN, err.Error())
+		}
+
+		j := assumeRoleResponse{}
+		if err = json.Unmarshal(output, &j); err != nil {
+			return nil, fmt.Errorf("Unable to assume role '%s'. %s", c.config.RoleARN, err.Error())
+		}
+		c.credentials = &j.Credentials
+	}
+
+	return credentialsEnv(os.Environ(), c.credentials), nil
+}
+
+// credentialsEnv replaces any aws credentials or profile in environ with creds.
+func credentialsEnv(environ []string, creds *assumedCredentials) []string {
+	env := []string{}
+	for _, e := range environ {
+		switch strings.SplitN(e, "=", 2)[0] {
+		case "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN", "AWS_PROFILE", "AWS_DEFAULT_PROFILE":
+			continue
+		}
+		env = append(env, e)
+	}
+
+	return append(env,
+		"AWS_ACCESS_KEY_ID="+creds.AccessKeyId,
+		"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN="+creds.SessionToken,
+	)
+}
 
-	cmd := exec.Command(createCmd[0], createCmd[1:]...)
+func execAws(args []string, env []string) ([]byte, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		errMsg := fmt.Sprintf("Error: '%s'. Output: '%s'", err.Error(), string(output))
-		return "", errors.New(errMsg)
+		return nil, errors.New(errMsg)
+	}
+
+	return output, nil
+}
+
+func (c *awsCliClient) run(args []string) ([]byte, error) {
+	env, err := c.env()
+	if err != nil {
+		return nil, err
+	}
+
+	return execAws(append(args, c.globalArgs(args[1])...), env)
+}
+
+func (c *awsCliClient) runWithTemplate(input *StackInput, build func(string) []string) ([]byte, error) {
+	path := ""
+	if input.TemplateURL == "" {
+		var err error
+		if path, err = writeTemplateFile(input.TemplateBody); err != nil {
+			return nil, err
+		}
+		defer os.Remove(path)
+	}
+
+	args := build(path)
+
+	fmt.Fprintln(c.out, "Going to run with command:")
+	fmt.Fprintf(c.out, "%s\n", strings.Join(args, " "))
+
+	return c.run(args)
+}
+
+func (c *awsCliClient) DescribeStack(name string) (*Stack, error) {
+	output, err := c.run(describeStackCmd(name))
+	if err != nil {
+		return nil, err
+	}
+
+	j := describeStackResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return nil, err
+	}
+
+	if len(j.Stacks) == 0 {
+		return nil, fmt.Errorf("Stack '%s' does not exist", name)
+	}
+
+	s := j.Stacks[0]
+	stack := &Stack{
+		StackId:            s.StackId,
+		StackName:          s.StackName,
+		StackStatus:        s.StackStatus,
+		StackStatusReason:  s.StackStatusReason,
+		Parameters:         map[string]string{},
+		Tags:               map[string]string{},
+		ResolvedParameters: map[string]string{},
+		StackSettings: StackSettings{
+			NotificationARNs:            s.NotificationARNs,
+			RoleARN:                     s.RoleARN,
+			TimeoutInMinutes:            s.TimeoutInMinutes,
+			EnableTerminationProtection: s.EnableTerminationProtection,
+			RollbackConfiguration:       s.RollbackConfiguration,
+		},
+	}
+	for _, p := range s.Parameters {
+		stack.Parameters[p.ParameterKey] = p.ParameterValue
+		if p.ResolvedValue != "" {
+			stack.ResolvedParameters[p.ParameterKey] = p.ResolvedValue
+		}
+	}
+	for _, t := range s.Tags {
+		stack.Tags[t.Key] = t.Value
 	}
 
-	return string(output), nil
+	return stack, nil
 }
 
-func newStackTemplateFile(sourceStack string, path string) (string, error) {
-	t, err := template(sourceStack, path)
+func (c *awsCliClient) GetTemplate(name string) (string, error) {
+	output, err := c.run(stackTemplateCmd(name))
 	if err != nil {
 		return "", err
 	}
 
-	f, err := ioutil.TempFile("", "cfn-clone")
+	j := getTemplateResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.template()
+}
+
+func (c *awsCliClient) GetStackPolicy(name string) (string, error) {
+	output, err := c.run(stackCmd("get-stack-policy", "--stack-name", name))
 	if err != nil {
-		fmt.Printf("Unable to create temp file for template. Error: %v", err)
 		return "", err
 	}
 
-	defer f.Close()
+	j := getStackPolicyResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.StackPolicyBody, nil
+}
+
+func (c *awsCliClient) SetStackPolicy(name string, body string) error {
+	_, err := c.run(stackCmd("set-stack-policy", "--stack-name", name, "--stack-policy-body", body))
+	return err
+}
+
+func (c *awsCliClient) UpdateTerminationProtection(name string, enable bool) error {
+	_, err := c.run(updateTerminationProtectionCmd(name, enable))
+	return err
+}
 
-	_, err = f.WriteString(t)
+func (c *awsCliClient) CreateStack(input *StackInput) (string, error) {
+	output, err := c.runWithTemplate(input, func(path string) []string {
+		return createStackCmd(input, path)
+	})
 	if err != nil {
-		fmt.Printf("Unable to write to temp file for template. Error: %v", err)
 		return "", err
 	}
 
-	if err = f.Sync(); err != nil {
-		fmt.Printf("Unable to flush write to temp file for template. Error: %v", err)
+	return stackIdFromOutput(output)
+}
+
+func (c *awsCliClient) UpdateStack(input *StackInput) (string, error) {
+	output, err := c.runWithTemplate(input, func(path string) []string {
+		return updateStackCmd(input, path)
+	})
+	if err != nil {
 		return "", err
 	}
 
-	return f.Name(), nil
+	return stackIdFromOutput(output)
+}
+
+func (c *awsCliClient) DeleteStack(name string) error {
+	_, err := c.run(stackCmd("delete-stack", "--stack-name", name))
+	return err
 }
 
-func noEchoParamsOverriden(params map[string]string) error {
-	for k, v := range params {
-		if v == "****" {
-			return fmt.Errorf("NoEcho Paramater '%s' must have overrid value specified.", k)
+func (c *awsCliClient) DescribeStackEvents(name string, seen map[string]bool) ([]StackEvent, error) {
+	events := []StackEvent{}
+	token := ""
+	for {
+		output, err := c.run(describeStackEventsCmd(name, token))
+		if err != nil {
+			return nil, err
+		}
+
+		j := describeStackEventsResponse{}
+		if err = json.Unmarshal(output, &j); err != nil {
+			return nil, err
+		}
+
+		events = append(events, j.StackEvents...)
+		if j.NextToken == "" || reachesSeen(j.StackEvents, seen) {
+			return events, nil
 		}
+		token = j.NextToken
 	}
-	return nil
 }
 
-func cliParamsForCreate(params map[string]string) []string {
-	p := []string{}
-	for k, v := range params {
-		escapedK := strings.Replace(k, ",", "\\,", -1)
-		escapedV := strings.Replace(v, ",", "\\,", -1)
-		p = append(p, "ParameterKey="+escapedK+",ParameterValue=\""+escapedV+"\"")
+func (c *awsCliClient) CreateChangeSet(input *ChangeSetInput) (string, error) {
+	output, err := c.runWithTemplate(&input.StackInput, func(path string) []string {
+		return createChangeSetCmd(input, path)
+	})
+	if err != nil {
+		return "", err
 	}
 
-	return p
+	j := struct{ Id string }{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.Id, nil
+}
+
+func (c *awsCliClient) DescribeChangeSet(stack string, changeSet string) (*ChangeSet, error) {
+	output, err := c.run(changeSetCmd("describe-change-set", stack, changeSet))
+	if err != nil {
+		return nil, err
+	}
+
+	j := describeChangeSetResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return nil, err
+	}
+
+	cs := &ChangeSet{
+		ChangeSetId:     j.ChangeSetId,
+		StackId:         j.StackId,
+		ChangeSetName:   j.ChangeSetName,
+		Status:          j.Status,
+		StatusReason:    j.StatusReason,
+		ExecutionStatus: j.ExecutionStatus,
+	}
+	for _, ch := range j.Changes {
+		cs.Changes = append(cs.Changes, ch.ResourceChange)
+	}
+
+	return cs, nil
+}
+
+func (c *awsCliClient) ExecuteChangeSet(stack string, changeSet string) error {
+	_, err := c.run(changeSetCmd("execute-change-set", stack, changeSet))
+	return err
 }
 
-func stackParametersCmd(stack string) []string {
-	return []string{
+func (c *awsCliClient) DeleteChangeSet(stack string, changeSet string) error {
+	_, err := c.run(changeSetCmd("delete-change-set", stack, changeSet))
+	return err
+}
+
+func stackIdFromOutput(output []byte) (string, error) {
+	j := struct{ StackId string }{}
+	if err := json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.StackId, nil
+}
+
+func stackCmd(action string, args ...string) []string {
+	cmd := []string{
 		"aws",
 		"cloudformation",
-		"describe-stacks",
+		action,
 		"--output",
 		"json",
-		"--stack-name",
-		stack,
 	}
+
+	return append(cmd, args...)
 }
 
-func stackParameters(stack string) (map[string]string, error) {
-	paramsCmd := stackParametersCmd(stack)
+// stackInputCmd builds the command for action on input. An update always
+// sends the notification ARNs and rollback configuration, empty when they
+// were dropped, as leaving them out keeps the stack's current ones.
+func stackInputCmd(action string, input *StackInput, template string, update bool) []string {
+	cmd := stackCmd(action, "--stack-name", input.StackName)
 
-	cmd := exec.Command(paramsCmd[0], paramsCmd[1:]...)
+	if input.TemplateURL != "" {
+		cmd = append(cmd, "--template-url", input.TemplateURL)
+	} else {
+		cmd = append(cmd, "--template-body", "file:///"+template)
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		errMsg := fmt.Sprintf("Error: '%s'. Output: '%s'", err.Error(), string(output))
-		return map[string]string{}, errors.New(errMsg)
+	if len(input.Capabilities) > 0 {
+		cmd = append(cmd, "--capabilities")
+		cmd = append(cmd, input.Capabilities...)
 	}
 
-	j := describeStackResponse{}
-	if err = json.Unmarshal([]byte(string(output)), &j); err != nil {
-		return map[string]string{}, err
+	if len(input.Parameters) > 0 {
+		cmd = append(cmd, "--parameters")
+		cmd = append(cmd, cliParamsForCreate(input.parameterValues())...)
+		for _, k := range input.PreviousParameters {
+			cmd = append(cmd, "ParameterKey="+strings.Replace(k, ",", "\\,", -1)+",UsePreviousValue=true")
+		}
+	}
+
+	if len(input.Tags) > 0 {
+		cmd = append(cmd, "--tags")
+		cmd = append(cmd, cliTagsForCreate(input.Tags)...)
+	}
+
+	if len(input.NotificationARNs) > 0 {
+		cmd = append(cmd, "--notification-arns")
+		cmd = append(cmd, input.NotificationARNs...)
+	} else if update {
+		cmd = append(cmd, "--notification-arns", "[]")
+	}
+
+	if input.RoleARN != "" {
+		cmd = append(cmd, "--role-arn", input.RoleARN)
 	}
 
-	params := map[string]string{}
-	for _, p := range j.Stacks[0].Parameters {
-		params[p.ParameterKey] = p.ParameterValue
+	if input.RollbackConfiguration != nil {
+		j, _ := json.Marshal(input.RollbackConfiguration)
+		cmd = append(cmd, "--rollback-configuration", string(j))
+	} else if update {
+		cmd = append(cmd, "--rollback-configuration", `{"RollbackTriggers":[]}`)
 	}
 
-	return params, nil
+	return cmd
 }
 
-func stackTemplateCmd(name string) []string {
-	return []string{
+func createStackCmd(input *StackInput, template string) []string {
+	cmd := stackInputCmd("create-stack", input, template, false)
+
+	if input.StackPolicyBody != "" {
+		cmd = append(cmd, "--stack-policy-body", input.StackPolicyBody)
+	}
+
+	if input.TimeoutInMinutes > 0 {
+		cmd = append(cmd, "--timeout-in-minutes", strconv.FormatInt(input.TimeoutInMinutes, 10))
+	}
+
+	if input.EnableTerminationProtection {
+		cmd = append(cmd, "--enable-termination-protection")
+	}
+
+	return cmd
+}
+
+func updateStackCmd(input *StackInput, template string) []string {
+	cmd := stackInputCmd("update-stack", input, template, true)
+
+	if input.StackPolicyBody != "" {
+		cmd = append(cmd, "--stack-policy-body", input.StackPolicyBody)
+	}
+
+	return cmd
+}
+
+func createChangeSetCmd(input *ChangeSetInput, template string) []string {
+	return append(stackInputCmd("create-change-set", &input.StackInput, template, input.ChangeSetType == changeSetTypeUpdate),
+		"--change-set-name",
+		input.ChangeSetName,
+		"--change-set-type",
+		input.ChangeSetType,
+	)
+}
+
+func updateTerminationProtectionCmd(name string, enable bool) []string {
+	flag := "--no-enable-termination-protection"
+	if enable {
+		flag = "--enable-termination-protection"
+	}
+	return stackCmd("update-termination-protection", "--stack-name", name, flag)
+}
+
+func changeSetCmd(action string, stack string, changeSet string) []string {
+	return stackCmd(action,
+		"--stack-name",
+		stack,
+		"--change-set-name",
+		changeSet,
+	)
+}
+
+func assumeRoleCmd(config clientConfig) []string {
+	cmd := []string{
 		"aws",
-		"cloudformation",
-		"get-template",
+		"sts",
+		"assume-role",
 		"--output",
 		"json",
-		"--stack-name",
-		name,
+		"--role-arn",
+		config.RoleARN,
+		"--role-session-name",
+		config.SessionName,
+	}
+
+	if config.ExternalID != "" {
+		cmd = append(cmd, "--external-id", config.ExternalID)
+	}
+
+	return cmd
+}
+
+func putObjectCmd(bucket string, key string, path string, kmsKeyID string) []string {
+	cmd := []string{"aws", "s3api", "put-object", "--output", "json", "--bucket", bucket, "--key", key, "--body", path}
+	if kmsKeyID != "" {
+		cmd = append(cmd, "--server-side-encryption", "aws:kms", "--ssekms-key-id", kmsKeyID)
 	}
+	return cmd
 }
 
-func stackTemplate(name string) (string, error) {
-	templateCmd := stackTemplateCmd(name)
+func deleteObjectCmd(bucket string, key string) []string {
+	return []string{"aws", "s3api", "delete-object", "--output", "json", "--bucket", bucket, "--key", key}
+}
 
-	cmd := exec.Command(templateCmd[0], templateCmd[1:]...)
+func getParameterCmd(name string) []string {
+	return []string{"aws", "ssm", "get-parameter", "--output", "json", "--with-decryption", "--name", name}
+}
 
-	output, err := cmd.CombinedOutput()
+func getSecretValueCmd(id string) []string {
+	return []string{"aws", "secretsmanager", "get-secret-value", "--output", "json", "--secret-id", id}
+}
+
+// describeStackEventsCmd asks for one page of a stack's events, so polling
+// need not page through the whole history.
+func describeStackEventsCmd(name string, token string) []string {
+	args := []string{"--stack-name", name, "--max-items", stackEventsPageSize}
+	if token != "" {
+		args = append(args, "--starting-token", token)
+	}
+	return stackCmd("describe-stack-events", args...)
+}
+
+func describeStackCmd(stack string) []string {
+	return stackCmd("describe-stacks", "--stack-name", stack)
+}
+
+func stackTemplateCmd(name string) []string {
+	return stackCmd("get-template", "--stack-name", name)
+}
+
+func writeTemplateFile(body string) (string, error) {
+	f, err := ioutil.TempFile("", "cfn-clone")
 	if err != nil {
-		errMsg := fmt.Sprintf("Error: '%s'. Output: '%s'", err.Error(), string(output))
-		return "", errors.New(errMsg)
+		return "", fmt.Errorf("Unable to create temp file for template. Error: %v", err)
 	}
 
-	j := map[string]interface{}{}
-	if err = json.Unmarshal([]byte(string(output)), &j); err != nil {
-		return "", err
+	defer f.Close()
+
+	if _, err = f.WriteString(body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("Unable to write to temp file for template. Error: %v", err)
 	}
 
-	template, err := json.Marshal(j["TemplateBody"])
-	if err != nil {
-		return "", err
+	if err = f.Sync(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("Unable to flush write to temp file for template. Error: %v", err)
+	}
+
+	return f.Name(), nil
+}
+
+func cliParamsForCreate(params map[string]string) []string {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	p := []string{}
+	for _, k := range keys {
+		escapedK := strings.Replace(k, ",", "\\,", -1)
+		escapedV := strings.Replace(params[k], ",", "\\,", -1)
+		p = append(p, "ParameterKey="+escapedK+",ParameterValue=\""+escapedV+"\"")
+	}
+
+	return p
+}
+
+func cliTagsForCreate(tags map[string]string) []string {
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	t := []string{}
+	for _, k := range keys {
+		escapedK := strings.Replace(k, ",", "\\,", -1)
+		escapedV := strings.Replace(tags[k], ",", "\\,", -1)
+		t = append(t, "Key="+escapedK+",Value=\""+escapedV+"\"")
 	}
 
-	return string(template), nil
+	return t
 }
 
-func template(sourceStack string, path string) (string, error) {
+func stackTemplate(client CloudFormationClient, name string) (string, error) {
+	return client.GetTemplate(name)
+}
+
+func template(client CloudFormationClient, sourceStack string, path string) (string, error) {
 	if path == "" {
-		return stackTemplate(sourceStack)
+		return stackTemplate(client, sourceStack)
 	} else {
 		t, err := ioutil.ReadFile(path)
 		if err != nil {
@@ -192,3 +656,50 @@ func template(sourceStack string, path string) (string, error) {
 		return string(t), nil
 	}
 }
+
+func (c *awsCliClient) GetParameter(name string) (string, error) {
+	output, err := c.run(getParameterCmd(name))
+	if err != nil {
+		return "", err
+	}
+
+	j := getParameterResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.Parameter.Value, nil
+}
+
+func (c *awsCliClient) GetSecretValue(id string) (string, error) {
+	output, err := c.run(getSecretValueCmd(id))
+	if err != nil {
+		return "", err
+	}
+
+	j := getSecretValueResponse{}
+	if err = json.Unmarshal(output, &j); err != nil {
+		return "", err
+	}
+
+	return j.SecretString, nil
+}
+
+func (c *awsCliClient) PutTemplate(bucket string, key string, body string, kmsKeyID string) (string, error) {
+	path, err := writeTemplateFile(body)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	if _, err = c.run(putObjectCmd(bucket, key, path, kmsKeyID)); err != nil {
+		return "", err
+	}
+
+	return templateURL(bucket, c.config.Region, key), nil
+}
+
+func (c *awsCliClient) DeleteTemplate(bucket string, key string) error {
+	_, err := c.run(deleteObjectCmd(bucket, key))
+	return err
+}
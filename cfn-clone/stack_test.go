@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -9,8 +10,8 @@ import (
 
 func TestCliParamsForCreate(t *testing.T) {
 	expected := []string{
-		"ParameterKey=foo,ParameterValue=\"bar\\,1\"",
 		"ParameterKey=bar,ParameterValue=\"2\\,baz\"",
+		"ParameterKey=foo,ParameterValue=\"bar\\,1\"",
 	}
 	params := map[string]string{"foo": "bar,1", "bar": "2,baz"}
 	result := cliParamsForCreate(params)
@@ -21,8 +22,11 @@ func TestCliParamsForCreate(t *testing.T) {
 }
 
 func TestCreateStackCmd(t *testing.T) {
-	name := "foo"
-	params := map[string]string{"param1": "val1,valx", "param2": "val2,valy"}
+	input := &StackInput{
+		StackName:    "foo",
+		Parameters:   map[string]string{"param2": "val2,valy", "param1": "val1,valx"},
+		Capabilities: []string{"CAPABILITY_IAM"},
+	}
 	template := "/var/tmp/new_template.json"
 
 	expected := []string{
@@ -32,7 +36,7 @@ func TestCreateStackCmd(t *testing.T) {
 		"--output",
 		"json",
 		"--stack-name",
-		name,
+		input.StackName,
 		"--template-body",
 		"file:///" + template,
 		"--capabilities",
@@ -42,21 +46,66 @@ func TestCreateStackCmd(t *testing.T) {
 		"ParameterKey=param2,ParameterValue=\"val2\\,valy\"",
 	}
 
-	cmd, _ := createStackCmd(name, params, template)
+	cmd := createStackCmd(input, template)
+
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+func TestCreateStackCmdWithTemplateURL(t *testing.T) {
+	input := &StackInput{StackName: "foo", TemplateURL: "https://bucket.s3.amazonaws.com/foo.json"}
 
-	// because order of maps are not guaranteed
-	if !reflect.DeepEqual(cmd[:8], expected[:8]) {
-		t.Fatalf("Expected '%s' got '%s'", expected[:8], cmd[:8])
+	expected := []string{"aws", "cloudformation", "create-stack", "--output", "json", "--stack-name", "foo", "--template-url", input.TemplateURL}
+	if cmd := createStackCmd(input, ""); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
 	}
+}
 
-	for i := 8; i < 10; i++ {
-		if expected[i] != cmd[8] && expected[i] != cmd[9] {
-			t.Fatalf("Missing parameter. Expected to find '%s' in '%s' or '%s'", expected[i], cmd[8], cmd[9])
-		}
+func TestPutObjectCmd(t *testing.T) {
+	expected := []string{"aws", "s3api", "put-object", "--output", "json", "--bucket", "b", "--key", "k", "--body", "/tmp/t", "--server-side-encryption", "aws:kms", "--ssekms-key-id", "alias/k"}
+	if cmd := putObjectCmd("b", "k", "/tmp/t", "alias/k"); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+
+	expected = []string{"aws", "s3api", "delete-object", "--output", "json", "--bucket", "b", "--key", "k"}
+	if cmd := deleteObjectCmd("b", "k"); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+func TestCreateChangeSetCmd(t *testing.T) {
+	input := &ChangeSetInput{
+		StackInput:    StackInput{StackName: "foo"},
+		ChangeSetName: "bar",
+		ChangeSetType: "CREATE",
+	}
+	template := "/var/tmp/new_template.json"
+
+	expected := []string{
+		"aws",
+		"cloudformation",
+		"create-change-set",
+		"--output",
+		"json",
+		"--stack-name",
+		"foo",
+		"--template-body",
+		"file:///" + template,
+		"--change-set-name",
+		"bar",
+		"--change-set-type",
+		"CREATE",
+	}
+
+	cmd := createChangeSetCmd(input, template)
+
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
 	}
 }
 
-func TestStackParamsCmd(t *testing.T) {
+func TestDescribeStackCmd(t *testing.T) {
 	name := "foo"
 
 	expected := []string{
@@ -69,7 +118,7 @@ func TestStackParamsCmd(t *testing.T) {
 		name,
 	}
 
-	cmd := stackParametersCmd(name)
+	cmd := describeStackCmd(name)
 
 	if !reflect.DeepEqual(cmd, expected) {
 		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
@@ -110,9 +159,224 @@ func TestTemplate(t *testing.T) {
 	f.Sync()
 	f.Close()
 
-	data, _ := template("", f.Name())
+	data, _ := template(newFakeClient(), "", f.Name())
 
 	if data != s {
 		t.Fatalf("Expected '%s' got '%s'", s, data)
 	}
 }
+
+func TestCliTagsForCreate(t *testing.T) {
+	expected := []string{
+		"Key=Env,Value=\"prod\"",
+		"Key=Teams,Value=\"a\\,b\"",
+	}
+	result := cliTagsForCreate(map[string]string{"Teams": "a,b", "Env": "prod"})
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+}
+
+var cliGlobalArgsTcs = []struct {
+	config  clientConfig
+	service string
+	args    []string
+}{
+	{clientConfig{}, "cloudformation", []string{}},
+	{clientConfig{Region: "us-west-2"}, "cloudformation", []string{"--region", "us-west-2"}},
+	{
+		clientConfig{EndpointURL: "http://localhost:4566", Region: "eu-west-1", Profile: "dr"},
+		"cloudformation",
+		[]string{"--endpoint-url", "http://localhost:4566", "--region", "eu-west-1", "--profile", "dr"},
+	},
+	{clientConfig{EndpointURL: "http://localhost:4566", Region: "eu-west-1"}, "sts", []string{"--region", "eu-west-1"}},
+	{clientConfig{EndpointURL: "http://localhost:4566"}, "ssm", []string{}},
+	{clientConfig{EndpointURL: "http://localhost:4566"}, "s3api", []string{}},
+}
+
+func TestCliGlobalArgs(t *testing.T) {
+	for _, tc := range cliGlobalArgsTcs {
+		args := newAwsCliClient(tc.config, ioutil.Discard).globalArgs(tc.service)
+		if !reflect.DeepEqual(args, tc.args) {
+			t.Fatalf("Expected '%v' got '%v'", tc.args, args)
+		}
+	}
+}
+
+func TestAssumeRoleCmd(t *testing.T) {
+	expected := []string{
+		"aws",
+		"sts",
+		"assume-role",
+		"--output",
+		"json",
+		"--role-arn",
+		"arn:aws:iam::123456789012:role/cloner",
+		"--role-session-name",
+		"cfn-clone",
+		"--external-id",
+		"ext-id",
+	}
+
+	cmd := assumeRoleCmd(clientConfig{RoleARN: "arn:aws:iam::123456789012:role/cloner", SessionName: "cfn-clone", ExternalID: "ext-id"})
+
+	if !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+func TestCredentialsEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "AWS_PROFILE=prod", "AWS_ACCESS_KEY_ID=old", "AWS_SECURITY_TOKEN=old", "AWS_DEFAULT_REGION=us-east-1"}
+	creds := &assumedCredentials{AccessKeyId: "ASIA", SecretAccessKey: "secret", SessionToken: "token"}
+
+	expected := []string{
+		"PATH=/bin",
+		"AWS_DEFAULT_REGION=us-east-1",
+		"AWS_ACCESS_KEY_ID=ASIA",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_SESSION_TOKEN=token",
+	}
+
+	env := credentialsEnv(environ, creds)
+
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, env)
+	}
+}
+
+func TestCliGlobalArgsWithAssumedRole(t *testing.T) {
+	c := newAwsCliClient(clientConfig{Profile: "prod", RoleARN: "arn:aws:iam::123456789012:role/cloner"}, ioutil.Discard)
+	c.credentials = &assumedCredentials{AccessKeyId: "ASIA"}
+
+	if args := c.globalArgs("cloudformation"); len(args) != 0 {
+		t.Fatalf("Expected the profile to be replaced by the assumed role got '%v'", args)
+	}
+}
+
+func TestSecretStoreCmds(t *testing.T) {
+	expected := []string{"aws", "ssm", "get-parameter", "--output", "json", "--with-decryption", "--name", "/app/db/pass"}
+	if cmd := getParameterCmd("/app/db/pass"); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+
+	expected = []string{"aws", "secretsmanager", "get-secret-value", "--output", "json", "--secret-id", "db"}
+	if cmd := getSecretValueCmd("db"); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+var getTemplateResponseTcs = []struct {
+	output   string
+	expected string
+}{
+	{`{"TemplateBody": "Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n"}`, "Resources:\n  Topic:\n    Type: AWS::SNS::Topic\n"},
+	{`{"TemplateBody": {"Resources": {"Topic": {"Type": "AWS::SNS::Topic"}}}}`, `{"Resources": {"Topic": {"Type": "AWS::SNS::Topic"}}}`},
+}
+
+func TestGetTemplateResponse(t *testing.T) {
+	for _, tc := range getTemplateResponseTcs {
+		j := getTemplateResponse{}
+		if err := json.Unmarshal([]byte(tc.output), &j); err != nil {
+			t.Fatalf("Expected no error got '%v'", err)
+		}
+
+		if body, err := j.template(); body != tc.expected || err != nil {
+			t.Fatalf("Expected '%v' got '%v' (%v)", tc.expected, body, err)
+		}
+	}
+}
+
+func TestCreateStackCmdWithSettings(t *testing.T) {
+	input := &StackInput{
+		StackName:   "foo",
+		TemplateURL: "https://bucket.s3.amazonaws.com/foo.json",
+		StackSettings: StackSettings{
+			NotificationARNs:            []string{"arn:a", "arn:b"},
+			RoleARN:                     "arn:role",
+			StackPolicyBody:             `{"Statement":[]}`,
+			TimeoutInMinutes:            30,
+			EnableTerminationProtection: true,
+			RollbackConfiguration: &RollbackConfiguration{
+				MonitoringTimeInMinutes: 5,
+				RollbackTriggers:        []RollbackTrigger{{Arn: "arn:alarm", Type: "AWS::CloudWatch::Alarm"}},
+			},
+		},
+	}
+
+	expected := []string{
+		"aws", "cloudformation", "create-stack", "--output", "json", "--stack-name", "foo", "--template-url", input.TemplateURL,
+		"--notification-arns", "arn:a", "arn:b",
+		"--role-arn", "arn:role",
+		"--rollback-configuration", `{"MonitoringTimeInMinutes":5,"RollbackTriggers":[{"Arn":"arn:alarm","Type":"AWS::CloudWatch::Alarm"}]}`,
+		"--stack-policy-body", `{"Statement":[]}`,
+		"--timeout-in-minutes", "30",
+		"--enable-termination-protection",
+	}
+	if cmd := createStackCmd(input, ""); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+
+	cmd := createChangeSetCmd(&ChangeSetInput{StackInput: *input, ChangeSetName: "cs", ChangeSetType: "CREATE"}, "")
+	for _, arg := range cmd {
+		if arg == "--stack-policy-body" || arg == "--timeout-in-minutes" || arg == "--enable-termination-protection" {
+			t.Fatalf("Expected change sets to leave out create only settings got '%s'", cmd)
+		}
+	}
+}
+
+func TestUpdateTerminationProtectionCmd(t *testing.T) {
+	expected := []string{"aws", "cloudformation", "update-termination-protection", "--output", "json", "--stack-name", "foo", "--no-enable-termination-protection"}
+	if cmd := updateTerminationProtectionCmd("foo", false); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+func TestDescribeStackEventsCmd(t *testing.T) {
+	expected := []string{"aws", "cloudformation", "describe-stack-events", "--output", "json", "--stack-name", "foo", "--max-items", "100"}
+	if cmd := describeStackEventsCmd("foo", ""); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+
+	expected = append(expected, "--starting-token", "next")
+	if cmd := describeStackEventsCmd("foo", "next"); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
+
+func TestCreateChangeSetCmdKeepsSettingsOnCreate(t *testing.T) {
+	input := &ChangeSetInput{
+		StackInput:    StackInput{StackName: "foo", TemplateURL: "https://bucket.s3.amazonaws.com/foo.json"},
+		ChangeSetName: "cs",
+		ChangeSetType: "CREATE",
+	}
+
+	for _, flag := range createChangeSetCmd(input, "") {
+		if flag == "--notification-arns" || flag == "--rollback-configuration" {
+			t.Fatalf("Expected no empty settings on create got '%s'", createChangeSetCmd(input, ""))
+		}
+	}
+}
+
+func TestCreateChangeSetCmdWithPreviousParameters(t *testing.T) {
+	input := &ChangeSetInput{
+		StackInput: StackInput{
+			StackName:          "foo",
+			TemplateURL:        "https://bucket.s3.amazonaws.com/foo.json",
+			Parameters:         map[string]string{"Kept": "****", "Size": "small"},
+			PreviousParameters: []string{"Kept"},
+		},
+		ChangeSetName: "cs",
+		ChangeSetType: "UPDATE",
+	}
+
+	expected := []string{
+		"aws", "cloudformation", "create-change-set", "--output", "json", "--stack-name", "foo", "--template-url", input.TemplateURL,
+		"--parameters", "ParameterKey=Size,ParameterValue=\"small\"", "ParameterKey=Kept,UsePreviousValue=true",
+		"--notification-arns", "[]", "--rollback-configuration", `{"RollbackTriggers":[]}`,
+		"--change-set-name", "cs", "--change-set-type", "UPDATE",
+	}
+	if cmd := createChangeSetCmd(input, ""); !reflect.DeepEqual(cmd, expected) {
+		t.Fatalf("Expected '%s' got '%s'", expected, cmd)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// inlineTemplateLimit is the largest template, in bytes, CloudFormation
+// accepts inline. Larger ones have to be staged in S3.
+const inlineTemplateLimit = 51200
+
+// needsStaging reports whether body is too large to pass inline.
+func needsStaging(body string) bool {
+	return len(body) > inlineTemplateLimit
+}
+
+// validateStaging checks a template too large to pass inline has somewhere
+// to be staged.
+func validateStaging(body string, bucket string) error {
+	if needsStaging(body) && bucket == "" {
+		return fmt.Errorf("The template is %d bytes, over the %d byte limit for passing it inline. Set --template-bucket to stage it in S3.", len(body), inlineTemplateLimit)
+	}
+	return nil
+}
+
+// stagedTemplateKey names the object a template is staged as, unique to the
+// stack and time so concurrent clones do not collide.
+func stagedTemplateKey(prefix string, stackName string, body string, now time.Time) string {
+	ext := ".json"
+	if templateFormat(body) == yamlFormat {
+		ext = ".yaml"
+	}
+	return prefix + stackName + "-" + now.UTC().Format("20060102T150405Z") + ext
+}
+
+// templateURL returns the https URL CloudFormation reads a staged template
+// from.
+func templateURL(bucket string, region string, key string) string {
+	host := bucket + ".s3.amazonaws.com"
+	if region != "" && region != "us-east-1" {
+		host = bucket + ".s3." + region + ".amazonaws.com"
+	}
+	return (&url.URL{Scheme: "https", Host: host, Path: "/" + key}).String()
+}
+
+// stageTemplate uploads the template of input when it is too large to pass
+// inline, pointing input at the staged copy. The returned func removes the
+// staged copy, unless --keep-staged-template is set.
+func stageTemplate(target CloudFormationClient, input *StackInput, options *options, now time.Time, out io.Writer) (func(), error) {
+	if !needsStaging(input.TemplateBody) {
+		return func() {}, nil
+	}
+
+	stager, ok := target.(templateStager)
+	if !ok {
+		return nil, fmt.Errorf("Unable to stage the template, the backend can not upload to S3")
+	}
+
+	bucket := options.TemplateBucket
+	key := stagedTemplateKey(options.TemplatePrefix, input.StackName, input.TemplateBody, now)
+
+	u, err := stager.PutTemplate(bucket, key, input.TemplateBody, options.TemplateKMSKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to stage the template to s3://%s/%s. %s", bucket, key, err.Error())
+	}
+	input.TemplateURL = u
+
+	fmt.Fprintf(out, "Staged the template to s3://%s/%s.\n", bucket, key)
+
+	return func() {
+		if options.KeepStagedTemplate {
+			fmt.Fprintf(out, "Keeping the staged template s3://%s/%s.\n", bucket, key)
+			return
+		}
+		if err := stager.DeleteTemplate(bucket, key); err != nil {
+			fmt.Fprintf(out, "Warning: unable to delete the staged template s3://%s/%s. %s\n", bucket, key, err.Error())
+		}
+	}, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validateStagingTcs = []struct {
+	size          int
+	bucket        string
+	resultIsError bool
+}{
+	{inlineTemplateLimit, "", false},
+	{inlineTemplateLimit + 1, "", true},
+	{inlineTemplateLimit + 1, "bucket", false},
+}
+
+func TestValidateStaging(t *testing.T) {
+	for _, tc := range validateStagingTcs {
+		err := validateStaging(strings.Repeat(" ", tc.size), tc.bucket)
+		if (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v' for %d bytes", tc.resultIsError, err, tc.size)
+		}
+	}
+}
+
+func TestStagedTemplateKey(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 30, 5, 0, time.UTC)
+
+	if k := stagedTemplateKey("templates/", "clone", `{"Resources": {}}`, now); k != "templates/clone-20261018T123005Z.json" {
+		t.Fatalf("Expected 'templates/clone-20261018T123005Z.json' got '%v'", k)
+	}
+
+	if k := stagedTemplateKey("", "clone", "Resources: {}", now); k != "clone-20261018T123005Z.yaml" {
+		t.Fatalf("Expected 'clone-20261018T123005Z.yaml' got '%v'", k)
+	}
+}
+
+var templateURLTcs = []struct {
+	region   string
+	key      string
+	expected string
+}{
+	{"", "t/clone.json", "https://bucket.s3.amazonaws.com/t/clone.json"},
+	{"us-east-1", "clone.json", "https://bucket.s3.amazonaws.com/clone.json"},
+	{"eu-west-1", "my clone.json", "https://bucket.s3.eu-west-1.amazonaws.com/my%20clone.json"},
+}
+
+func TestTemplateURL(t *testing.T) {
+	for _, tc := range templateURLTcs {
+		if u := templateURL("bucket", tc.region, tc.key); u != tc.expected {
+			t.Fatalf("Expected '%v' got '%v'", tc.expected, u)
+		}
+	}
+}
+
+func TestStageTemplate(t *testing.T) {
+	c := newFakeClient()
+	opts := &options{TemplateBucket: "bucket", TemplatePrefix: "t/", TemplateKMSKeyID: "key"}
+	now := time.Date(2026, 10, 18, 12, 30, 5, 0, time.UTC)
+
+	var out bytes.Buffer
+	input := &StackInput{StackName: "clone", TemplateBody: `{"Resources": {}}`}
+	cleanup, err := stageTemplate(c, input, opts, now, &out)
+	if err != nil || input.TemplateURL != "" || len(c.objects) != 0 {
+		t.Fatalf("Expected a small template to stay inline got '%v' (%v)", input.TemplateURL, err)
+	}
+	cleanup()
+
+	input.TemplateBody = `{"Description": "` + strings.Repeat("x", inlineTemplateLimit) + `"}`
+	if cleanup, err = stageTemplate(c, input, opts, now, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if input.TemplateURL != "https://bucket.s3.amazonaws.com/t/clone-20261018T123005Z.json" || c.objects["bucket/t/clone-20261018T123005Z.json"] != input.TemplateBody {
+		t.Fatalf("Expected the template to be staged got '%v'", input.TemplateURL)
+	}
+
+	cleanup()
+	if len(c.objects) != 0 {
+		t.Fatalf("Expected the staged template to be deleted got '%v'", c.objects)
+	}
+
+	opts.KeepStagedTemplate = true
+	if cleanup, err = stageTemplate(c, input, opts, now, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	cleanup()
+	if len(c.objects) != 1 {
+		t.Fatalf("Expected the staged template to be kept got '%v'", c.objects)
+	}
+}
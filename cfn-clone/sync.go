@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cloneTagPrefix starts the tags cfn-clone records on a clone.
+	cloneTagPrefix = "cfn-clone:"
+	// sourceTag records the stack a clone was cloned from.
+	sourceTag = cloneTagPrefix + "source"
+	// overridesTag records the parameters a clone overrode, space separated
+	// and split over numbered tags when they do not fit in one.
+	overridesTag = cloneTagPrefix + "overrides"
+
+	maxTagValueLength = 256
+)
+
+// cloneTags records where a clone came from and which of its parameters
+// were overridden, so a later sync can keep them.
+func cloneTags(source string, params map[string]string, overridden map[string]bool) map[string]string {
+	tags := map[string]string{sourceTag: source}
+
+	keys := []string{}
+	for k := range overridden {
+		if _, ok := params[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	values := []string{}
+	for _, k := range keys {
+		if n := len(values) - 1; n >= 0 && len(values[n])+1+len(k) <= maxTagValueLength {
+			values[n] += " " + k
+		} else {
+			values = append(values, k)
+		}
+	}
+
+	for i, v := range values {
+		key := overridesTag
+		if i > 0 {
+			key += ":" + strconv.Itoa(i+1)
+		}
+		tags[key] = v
+	}
+
+	return tags
+}
+
+// recordedOverrides returns the current values of the parameters clone
+// overrode, after checking it was cloned from source.
+func recordedOverrides(clone *Stack, source string, out io.Writer) (map[string]string, error) {
+	previous := map[string]string{}
+
+	recorded, ok := clone.Tags[sourceTag]
+	if !ok {
+		fmt.Fprintf(out, "Warning: stack '%s' has no %s tag, so no overridden parameters are kept.\n", clone.StackName, sourceTag)
+		return previous, nil
+	}
+
+	if recorded != source {
+		return nil, fmt.Errorf("Stack '%s' was cloned from '%s', not '%s'", clone.StackName, recorded, source)
+	}
+
+	for tag, v := range clone.Tags {
+		if tag != overridesTag && !strings.HasPrefix(tag, overridesTag+":") {
+			continue
+		}
+		for _, k := range strings.Fields(v) {
+			if current, ok := clone.Parameters[k]; ok {
+				previous[k] = current
+			}
+		}
+	}
+
+	return previous, nil
+}
+
+// keptParameters returns the kept keys still among params, in order.
+func keptParameters(params map[string]string, kept map[string]bool) []string {
+	keys := []string{}
+	for k := range kept {
+		if _, ok := params[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffableTemplate writes JSON templates the same way whatever their layout,
+// as the aws cli returns them re-indented, so only real changes are diffed.
+// YAML templates are returned as written.
+func diffableTemplate(body string) string {
+	if templateFormat(body) != jsonFormat {
+		return body
+	}
+
+	b, err := parseTemplateBody(body)
+	if err != nil {
+		return body
+	}
+	written, err := b.String()
+	if err != nil {
+		return body
+	}
+	return written
+}
+
+// syncClone updates the existing clone from the source stack's current
+// template and parameters through a change set, keeping the parameters the
+// clone overrode at their current values.
+func syncClone(source CloudFormationClient, target CloudFormationClient, options *options, redactor *redactor, in prompter, out io.Writer) error {
+	current, err := target.DescribeStack(options.NewName)
+	if err != nil {
+		return fmt.Errorf("Unable to find the clone '%s' to sync. %s", options.NewName, err.Error())
+	}
+
+	previous, err := recordedOverrides(current, options.SourceName, out)
+	if err != nil {
+		return err
+	}
+
+	currentTemplate, err := stackTemplate(target, options.NewName)
+	if err != nil {
+		return fmt.Errorf("Error getting the clone's template. %s", err.Error())
+	}
+
+	currentSettings := current.StackSettings
+	if currentSettings.StackPolicyBody, err = target.GetStackPolicy(options.NewName); err != nil {
+		return fmt.Errorf("Error getting the clone's stack policy. %s", err.Error())
+	}
+
+	input, plan, err := prepareClone(source, options, previous, redactor, in, out)
+	if err != nil {
+		return err
+	}
+
+	plan.compareWithClone(current, currentSettings, input, redactor)
+	plan.TemplateChanges = lineDiff(diffableTemplate(currentTemplate), diffableTemplate(input.TemplateBody))
+	fmt.Fprintln(out, plan)
+
+	if options.DryRun {
+		fmt.Fprintln(out, "Dry run, the clone was not updated.")
+		return nil
+	}
+
+	if len(plan.References) > 0 {
+		if err = resolveReferences(input, plan.References, target, redactor); err != nil {
+			return err
+		}
+	}
+
+	cleanup, err := stageTemplate(target, input, options, time.Now(), out)
+	if err != nil {
+		return err
+	}
+
+	var seen map[string]bool
+	if options.Wait {
+		if seen, err = stackEventIds(target, options.NewName); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	fmt.Fprintln(out, "Going to sync")
+
+	output, err := applyChangeSet(target, input, changeSetTypeUpdate, options, in, time.Now(), out)
+	cleanup()
+	switch err {
+	case nil:
+	case errChangeSetRejected:
+		fmt.Fprintf(out, "%s, the clone was not updated.\n", err)
+		return nil
+	case errNoChanges:
+		fmt.Fprintf(out, "Stack '%s' is already in sync with '%s'.\n", options.NewName, options.SourceName)
+		return nil
+	default:
+		return err
+	}
+
+	fmt.Fprintf(out, "Success with output '%s'.\n", output)
+
+	if options.Wait {
+		fmt.Fprintln(out, "Waiting for stack to complete")
+		if err = waitForStack(target, output, seen, out); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Stack '%s' updated.\n", options.NewName)
+	}
+
+	return nil
+}
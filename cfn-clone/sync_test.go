@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloneTags(t *testing.T) {
+	params := map[string]string{"foo": "1", "bar": "2", "baz": "3"}
+	overridden := map[string]bool{"foo": true, "bar": true, "dropped": true}
+
+	expected := map[string]string{sourceTag: "source", overridesTag: "bar foo"}
+	if result := cloneTags("source", params, overridden); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, result)
+	}
+
+	if result := cloneTags("source", params, map[string]bool{}); !reflect.DeepEqual(result, map[string]string{sourceTag: "source"}) {
+		t.Fatalf("Expected only the source tag got '%v'", result)
+	}
+}
+
+func TestCloneTagsSplitsLongOverrides(t *testing.T) {
+	params := map[string]string{}
+	overridden := map[string]bool{}
+	for _, c := range "abcdefghijklmnopqrstuvwxyz" {
+		k := strings.Repeat(string(c), 20)
+		params[k] = "v"
+		overridden[k] = true
+	}
+
+	tags := cloneTags("source", params, overridden)
+	if len(tags[overridesTag]) > maxTagValueLength || tags[overridesTag+":2"] == "" || tags[overridesTag+":3"] == "" {
+		t.Fatalf("Expected the overrides to be split over tags got '%v'", tags)
+	}
+
+	previous, _ := recordedOverrides(&Stack{Parameters: params, Tags: tags}, "source", &bytes.Buffer{})
+	if !reflect.DeepEqual(previous, params) {
+		t.Fatalf("Expected '%v' got '%v'", params, previous)
+	}
+}
+
+func TestRecordedOverrides(t *testing.T) {
+	clone := &Stack{
+		StackName:  "clone",
+		Parameters: map[string]string{"foo": "mine", "bar": "2", "password": "****"},
+		Tags:       map[string]string{sourceTag: "source", overridesTag: "foo password gone"},
+	}
+
+	var out bytes.Buffer
+	previous, err := recordedOverrides(clone, "source", &out)
+	expected := map[string]string{"foo": "mine", "password": "****"}
+	if err != nil || !reflect.DeepEqual(previous, expected) {
+		t.Fatalf("Expected '%v' got '%v' with '%v'", expected, previous, err)
+	}
+
+	if _, err = recordedOverrides(clone, "other", &out); err == nil {
+		t.Fatalf("Expected an error for a clone of another stack")
+	}
+
+	delete(clone.Tags, sourceTag)
+	previous, err = recordedOverrides(clone, "source", &out)
+	if err != nil || len(previous) != 0 || !strings.Contains(out.String(), "Warning: stack 'clone' has no cfn-clone:source tag") {
+		t.Fatalf("Expected a warning and nothing kept got '%v' '%s'", previous, out.String())
+	}
+}
+
+func newSyncFixture() *fakeClient {
+	c := newCloneFixture()
+	c.templates["source"] = "{\n  \"Parameters\": {\"foo\": {\"Type\": \"String\"}, \"baz\": {\"Type\": \"String\"}},\n  \"Resources\": {\"Queue\": {\"Type\": \"AWS::SQS::Queue\"}}\n}\n"
+	c.stacks["clone"] = &Stack{
+		StackName:  "clone",
+		Parameters: map[string]string{"foo": "mine", "baz": "old"},
+		Tags:       map[string]string{sourceTag: "source", overridesTag: "foo"},
+	}
+	c.templates["clone"] = "{\n  \"Parameters\": {\"foo\": {\"Type\": \"String\"}, \"baz\": {\"Type\": \"String\"}},\n  \"Resources\": {}\n}\n"
+	return c
+}
+
+func TestSyncClone(t *testing.T) {
+	c := newSyncFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Sync: true, Approve: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if len(c.created) != 0 || len(c.createdChangeSet) != 1 || len(c.executed) != 1 {
+		t.Fatalf("Expected the clone to be updated through a change set got '%v' '%v'", c.created, c.createdChangeSet)
+	}
+
+	cs := c.createdChangeSet[0]
+	if cs.ChangeSetType != "UPDATE" || cs.TemplateBody != c.templates["source"] {
+		t.Fatalf("Expected an UPDATE change set with the source template got '%v'", cs)
+	}
+
+	if cs.Parameters["baz"] != "qux" || !reflect.DeepEqual(cs.PreviousParameters, []string{"foo"}) {
+		t.Fatalf("Expected baz from the source and foo kept got '%v' '%v'", cs.Parameters, cs.PreviousParameters)
+	}
+
+	if cs.Tags[overridesTag] != "foo" || cs.Tags[sourceTag] != "source" {
+		t.Fatalf("Expected the overrides to stay recorded got '%v'", cs.Tags)
+	}
+
+	for _, line := range []string{
+		`Sync plan:`,
+		`~ baz\s+old -> qux\n`,
+		`= foo\s+mine\n`,
+		`Parameters kept at the clone's values: foo\n`,
+		`Template changes \(clone -> synced\):\n(.*\n)*  -   "Resources": \{\}\n  \+   "Resources": \{\n  \+     "Queue": \{\n`,
+	} {
+		if !regexp.MustCompile(line).MatchString(out.String()) {
+			t.Fatalf("Expected output to show '%s' got '%s'", line, out.String())
+		}
+	}
+}
+
+var syncDropsTcs = []struct {
+	name  string
+	opts  options
+	check func(c *fakeClient, cs *ChangeSetInput) bool
+}{
+	{"--no-notifications", options{NoNotifications: true}, func(c *fakeClient, cs *ChangeSetInput) bool {
+		return len(cs.NotificationARNs) == 0
+	}},
+	{"--no-rollback-configuration", options{NoRollbackConfiguration: true}, func(c *fakeClient, cs *ChangeSetInput) bool {
+		return cs.RollbackConfiguration == nil
+	}},
+	{"--no-stack-policy", options{NoStackPolicy: true}, func(c *fakeClient, cs *ChangeSetInput) bool {
+		return cs.StackPolicyBody == "" && reflect.DeepEqual(c.setPolicies, map[string]string{"arn:aws:cloudformation:us-east-1:123456789012:stack/clone/1": allowAllStackPolicy})
+	}},
+	{"--no-termination-protection", options{NoTerminationProtection: true}, func(c *fakeClient, cs *ChangeSetInput) bool {
+		return reflect.DeepEqual(c.protected, map[string]bool{"arn:aws:cloudformation:us-east-1:123456789012:stack/clone/1": false})
+	}},
+}
+
+func TestSyncCloneDropsSettings(t *testing.T) {
+	for _, tc := range syncDropsTcs {
+		c := newSyncFixture()
+		settings := StackSettings{
+			NotificationARNs:            []string{"arn:aws:sns:us-east-1:111111111111:events"},
+			EnableTerminationProtection: true,
+			RollbackConfiguration: &RollbackConfiguration{
+				RollbackTriggers: []RollbackTrigger{{Arn: "arn:aws:cloudwatch:us-east-1:111111111111:alarm:errors", Type: "AWS::CloudWatch::Alarm"}},
+			},
+		}
+		c.stacks["source"].StackSettings = settings
+		c.stacks["clone"].StackSettings = settings
+		c.policies["source"] = `{"Statement":[]}`
+		c.policies["clone"] = `{"Statement":[]}`
+
+		opts := tc.opts
+		opts.SourceName, opts.NewName, opts.Sync, opts.Approve = "source", "clone", true, true
+
+		var out bytes.Buffer
+		if err := clone(c, c, &opts, testRedactor(), nil, &out); err != nil {
+			t.Fatalf("Expected no error for %s got '%v'", tc.name, err)
+		}
+
+		if !tc.check(c, c.createdChangeSet[0]) {
+			t.Fatalf("Expected %s to drop the setting from the clone got '%v' '%v' '%v'", tc.name, c.createdChangeSet[0].StackSettings, c.setPolicies, c.protected)
+		}
+	}
+}
+
+func TestSyncCloneWaitsForTheUpdate(t *testing.T) {
+	stackPollInterval = time.Millisecond
+
+	c := &pollingClient{fakeClient: newSyncFixture(), batches: [][]StackEvent{
+		{stackEvent("1", "clone", "CREATE_COMPLETE", "")},
+		{stackEvent("2", "clone", "UPDATE_IN_PROGRESS", "User Initiated")},
+		{stackEvent("3", "clone", "UPDATE_COMPLETE", "")},
+	}}
+	opts := &options{SourceName: "source", NewName: "clone", Sync: true, Approve: true, Wait: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if !strings.Contains(out.String(), "UPDATE_COMPLETE") || strings.Contains(out.String(), "CREATE_COMPLETE") {
+		t.Fatalf("Expected to wait for the update rather than the creation got '%s'", out.String())
+	}
+}
+
+func TestSyncCloneOverriddenAgain(t *testing.T) {
+	c := newSyncFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Sync: true, Approve: true, Attributes: []string{"foo=new", "baz=set"}}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	cs := c.createdChangeSet[0]
+	expected := map[string]string{"foo": "new", "baz": "set"}
+	if !reflect.DeepEqual(cs.Parameters, expected) || len(cs.PreviousParameters) != 0 || cs.Tags[overridesTag] != "baz foo" {
+		t.Fatalf("Expected '%v' with both recorded got '%v' '%v' '%v'", expected, cs.Parameters, cs.PreviousParameters, cs.Tags)
+	}
+}
+
+func TestSyncCloneIgnoresTemplateLayout(t *testing.T) {
+	c := newSyncFixture()
+	c.templates["clone"] = `{"Parameters":{"foo":{"Type":"String"},"baz":{"Type":"String"}},"Resources":{"Queue":{"Type":"AWS::SQS::Queue"}}}`
+	opts := &options{SourceName: "source", NewName: "clone", Sync: true, DryRun: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if !strings.Contains(out.String(), "Template changes: none") {
+		t.Fatalf("Expected no template changes for a re-indented template got '%s'", out.String())
+	}
+}
+
+func TestSyncCloneDryRunAndNoChanges(t *testing.T) {
+	c := newSyncFixture()
+	opts := &options{SourceName: "source", NewName: "clone", Sync: true, DryRun: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if len(c.createdChangeSet) != 0 || !strings.Contains(out.String(), "Dry run, the clone was not updated.") {
+		t.Fatalf("Expected nothing to be changed during a dry run got '%v'", c.createdChangeSet)
+	}
+
+	opts.DryRun = false
+	opts.Approve = true
+	c.nextChangeSet = &ChangeSet{Status: "FAILED", StatusReason: "The submitted information didn't contain changes. Submit different information to create a change set."}
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	if len(c.executed) != 0 || len(c.deletedChangeSet) != 1 || len(c.deleted) != 0 || !strings.Contains(out.String(), "Stack 'clone' is already in sync with 'source'.") {
+		t.Fatalf("Expected the empty change set to be deleted got '%v' '%v' '%s'", c.executed, c.deleted, out.String())
+	}
+}
+
+func TestSyncCloneErrors(t *testing.T) {
+	c := newSyncFixture()
+	opts := &options{SourceName: "source", NewName: "missing", Sync: true, Approve: true}
+
+	var out bytes.Buffer
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil {
+		t.Fatalf("Expected an error syncing a clone that does not exist")
+	}
+
+	opts.NewName = "clone"
+	c.stacks["clone"].Tags[sourceTag] = "elsewhere"
+	if err := clone(c, c, opts, testRedactor(), nil, &out); err == nil || !strings.Contains(err.Error(), "was cloned from 'elsewhere'") {
+		t.Fatalf("Expected an error syncing from another source got '%v'", err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// cfnTemplate is the part of a CloudFormation template cfn-clone inspects.
+type cfnTemplate struct {
+	Transform  interface{}
+	Parameters map[string]templateParameter
+	Resources  map[string]templateResource
+}
+
+// templateResource is a single entry of a template's Resources section.
+type templateResource struct {
+	Type       string
+	Properties map[string]interface{}
+}
+
+// templateParameter is a single entry of a template's Parameters section.
+type templateParameter struct {
+	Type                  string
+	Description           string
+	Default               interface{}
+	NoEcho                cfnBool
+	AllowedValues         []interface{}
+	AllowedPattern        string
+	MinLength             *cfnNumber
+	MaxLength             *cfnNumber
+	MinValue              *cfnNumber
+	MaxValue              *cfnNumber
+	ConstraintDescription string
+}
+
+// cfnBool is a template boolean, which may be written as true or "true".
+type cfnBool bool
+
+func (b *cfnBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case bool:
+		*b = cfnBool(t)
+	case string:
+		parsed, err := strconv.ParseBool(t)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a boolean", t)
+		}
+		*b = cfnBool(parsed)
+	default:
+		return fmt.Errorf("'%s' is not a boolean", string(data))
+	}
+
+	return nil
+}
+
+// cfnNumber is a template number, which may be written as 5 or "5".
+type cfnNumber float64
+
+func (n *cfnNumber) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case float64:
+		*n = cfnNumber(t)
+	case string:
+		parsed, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", t)
+		}
+		*n = cfnNumber(parsed)
+	default:
+		return fmt.Errorf("'%s' is not a number", string(data))
+	}
+
+	return nil
+}
+
+// parseTemplate reads a JSON or YAML template. YAML is converted to the
+// JSON model first, so both are read the same way.
+func parseTemplate(body string) (*cfnTemplate, error) {
+	data := []byte(body)
+	if templateFormat(body) == yamlFormat {
+		b, err := parseTemplateBody(body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse template. %s", err.Error())
+		}
+
+		v, err := b.value()
+		if err == nil {
+			data, err = json.Marshal(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse template. %s", err.Error())
+		}
+	}
+
+	t := &cfnTemplate{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("Unable to parse template. %s", err.Error())
+	}
+
+	if t.Parameters == nil {
+		t.Parameters = map[string]templateParameter{}
+	}
+	if t.Resources == nil {
+		t.Resources = map[string]templateResource{}
+	}
+
+	return t, nil
+}
+
+// noEchoParameters returns the names of the template's NoEcho parameters.
+func (t *cfnTemplate) noEchoParameters() []string {
+	keys := []string{}
+	for k, p := range t.Parameters {
+		if p.NoEcho {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// maskedParameters returns the keys of params copied from a stack made from
+// source whose template declares them NoEcho, as CloudFormation only ever
+// returns those masked. Keys in overridden were given a new value. Without a
+// source template, a masked looking value is the only hint left.
+func maskedParameters(source *cfnTemplate, params map[string]string, overridden map[string]bool) []string {
+	keys := []string{}
+	for k, v := range params {
+		if overridden[k] {
+			continue
+		}
+
+		if source != nil {
+			if source.Parameters[k].NoEcho {
+				keys = append(keys, k)
+			}
+		} else if v == redactedValue {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// parameterDescription returns the description of parameter key from the
+// first template declaring one.
+func parameterDescription(key string, templates ...*cfnTemplate) string {
+	for _, t := range templates {
+		if t != nil && t.Parameters[key].Description != "" {
+			return t.Parameters[key].Description
+		}
+	}
+	return ""
+}
+
+// transforms returns the names of the template level transforms, which may
+// be written as a single name or a list.
+func (t *cfnTemplate) transforms() []string {
+	names := []string{}
+	switch v := t.Transform.(type) {
+	case string:
+		names = append(names, v)
+	case []interface{}:
+		for _, n := range v {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	body := `{
+		"Parameters": {
+			"DbPassword": {"Type": "String", "NoEcho": true},
+			"ApiKey": {"Type": "String", "NoEcho": "true"},
+			"Env": {"Type": "String", "Description": "Environment name"}
+		},
+		"Resources": {}
+	}`
+
+	tmpl, err := parseTemplate(body)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if tmpl.Parameters["Env"].Description != "Environment name" {
+		t.Fatalf("Expected description got '%v'", tmpl.Parameters["Env"])
+	}
+
+	noEcho := tmpl.noEchoParameters()
+	sort.Strings(noEcho)
+	expected := []string{"ApiKey", "DbPassword"}
+	if !reflect.DeepEqual(noEcho, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, noEcho)
+	}
+}
+
+var parseTemplateErrorTcs = []string{
+	`not a template`,
+	`{"Parameters": {"Foo": {"NoEcho": "maybe"}}}`,
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	for _, tc := range parseTemplateErrorTcs {
+		if _, err := parseTemplate(tc); err == nil {
+			t.Fatalf("Expected error parsing '%s'", tc)
+		}
+	}
+}
+
+func TestMaskedParameters(t *testing.T) {
+	source, err := parseTemplate(`{"Parameters": {"Password": {"Type": "String", "NoEcho": "true"}, "Stars": {"Type": "String"}, "Token": {"Type": "String", "NoEcho": true}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	params := map[string]string{"Password": "****", "Stars": "****", "Token": "****"}
+
+	tcs := []struct {
+		source     *cfnTemplate
+		overridden map[string]bool
+		expected   []string
+	}{
+		{source, nil, []string{"Password", "Token"}},
+		{source, map[string]bool{"Token": true}, []string{"Password"}},
+		{nil, nil, []string{"Password", "Stars", "Token"}},
+	}
+
+	for _, tc := range tcs {
+		if masked := maskedParameters(tc.source, params, tc.overridden); !reflect.DeepEqual(masked, tc.expected) {
+			t.Fatalf("Expected '%v' got '%v'", tc.expected, masked)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	jsonFormat = "json"
+	yamlFormat = "yaml"
+)
+
+// templateBody is a template as written, held as a yaml node tree so it can
+// be edited and written back in its original format. JSON is read with a
+// JSON decoder, as it allows escapes YAML does not.
+type templateBody struct {
+	format string
+	doc    *yaml.Node
+}
+
+// templateFormat reports whether body is a JSON or a YAML template.
+func templateFormat(body string) string {
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return jsonFormat
+	}
+	return yamlFormat
+}
+
+func parseTemplateBody(body string) (*templateBody, error) {
+	doc := &yaml.Node{}
+	if templateFormat(body) == jsonFormat {
+		root, err := parseJSONNode(body)
+		if err != nil {
+			return nil, err
+		}
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	} else if err := yaml.Unmarshal([]byte(body), doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("The template must be a map of sections")
+	}
+
+	return &templateBody{format: templateFormat(body), doc: doc}, nil
+}
+
+// parseJSONNode reads a JSON document into the node tree yaml.v3 would have
+// built for it, keeping the order of the keys.
+func parseJSONNode(body string) (*yaml.Node, error) {
+	d := json.NewDecoder(strings.NewReader(body))
+	d.UseNumber()
+
+	n, err := readJSONNode(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Unexpected content after the template")
+	}
+
+	return n, nil
+}
+
+func readJSONNode(d *json.Decoder) (*yaml.Node, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := t.(type) {
+	case json.Delim:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if v == '[' {
+			n = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		for d.More() {
+			if n.Kind == yaml.MappingNode {
+				k, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, stringNode(k.(string)))
+			}
+			c, err := readJSONNode(d)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, c)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return stringNode(v), nil
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.String()}, nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}, nil
+	}
+
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+}
+
+// root returns the top level map of the template.
+func (b *templateBody) root() *yaml.Node {
+	return b.doc.Content[0]
+}
+
+// value converts the template into plain maps, lists and scalars, turning
+// short form tags such as !Ref and !GetAtt into their long forms.
+func (b *templateBody) value() (interface{}, error) {
+	return nodeValue(b.doc)
+}
+
+func (b *templateBody) String() (string, error) {
+	if b.format == yamlFormat {
+		var out bytes.Buffer
+		e := yaml.NewEncoder(&out)
+		e.SetIndent(2)
+		if err := e.Encode(b.doc); err != nil {
+			return "", err
+		}
+		e.Close()
+		return out.String(), nil
+	}
+
+	var compact bytes.Buffer
+	if err := writeJSONNode(&compact, b.root()); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	out.WriteString("\n")
+	return out.String(), nil
+}
+
+// mappingValue returns the value of key in the map n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setString replaces the scalar n with the string v.
+func setString(n *yaml.Node, v string) {
+	n.Kind = yaml.ScalarNode
+	n.Tag = "!!str"
+	n.Value = v
+	n.Content = nil
+}
+
+// shortFormFunction returns the long form of a short form function tag,
+// !Ref becoming Ref and !Sub becoming Fn::Sub.
+func shortFormFunction(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, "!") || strings.HasPrefix(tag, "!!") {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(tag, "!")
+	if name == "Ref" || name == "Condition" {
+		return name, true
+	}
+	return "Fn::" + name, true
+}
+
+func nodeValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return nodeValue(n.Content[0])
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	}
+
+	var v interface{}
+	switch n.Kind {
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			item, err := nodeValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[n.Content[i].Value] = item
+		}
+		v = m
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, c := range n.Content {
+			item, err := nodeValue(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		v = list
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!int", "!!float", "!!bool", "!!null":
+			if err := n.Decode(&v); err != nil {
+				return nil, err
+			}
+		default:
+			v = n.Value
+		}
+	}
+
+	fn, ok := shortFormFunction(n.Tag)
+	if !ok {
+		return v, nil
+	}
+
+	if s, isString := v.(string); isString && fn == "Fn::GetAtt" {
+		parts := strings.SplitN(s, ".", 2)
+		list := []interface{}{}
+		for _, p := range parts {
+			list = append(list, p)
+		}
+		v = list
+	}
+
+	return map[string]interface{}{fn: v}, nil
+}
+
+// writeJSONNode writes a node tree read from a JSON template back as
+// compact JSON, keeping the order of every map.
+func writeJSONNode(b *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		b.WriteString("{")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			writeJSONString(b, n.Content[i].Value)
+			b.WriteString(":")
+			if err := writeJSONNode(b, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	case yaml.SequenceNode:
+		b.WriteString("[")
+		for i, c := range n.Content {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := writeJSONNode(b, c); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	case yaml.ScalarNode:
+		switch {
+		case n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 || n.Tag == "!!str":
+			writeJSONString(b, n.Value)
+		case n.Tag == "!!null":
+			b.WriteString("null")
+		default:
+			b.WriteString(n.Value)
+		}
+	default:
+		return fmt.Errorf("Unable to write template, unexpected YAML node at line %d", n.Line)
+	}
+
+	return nil
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	e := json.NewEncoder(b)
+	e.SetEscapeHTML(false)
+	e.Encode(s)
+	b.Truncate(b.Len() - 1)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlTemplate = `AWSTemplateFormatVersion: 2010-09-09
+Transform: AWS::Serverless-2016-10-31
+Parameters:
+  # The image to run
+  Image:
+    Type: AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>
+  Password:
+    Type: String
+    NoEcho: true
+    MinLength: 8
+Resources:
+  Instance:
+    Type: AWS::EC2::Instance
+    Properties:
+      ImageId: !Ref Image
+      UserData: !Base64
+        Fn::Sub: "echo ${AWS::Region}"
+      Tags:
+        - Key: Zone
+          Value: !GetAtt Subnet.AvailabilityZone
+        - Key: Name
+          Value: !Join [ "-", [ !Ref "AWS::StackName", app ] ]
+`
+
+var templateFormatTcs = []struct {
+	body     string
+	expected string
+}{
+	{`{"Resources": {}}`, jsonFormat},
+	{"\n  {\n}", jsonFormat},
+	{yamlTemplate, yamlFormat},
+	{"Resources: {}", yamlFormat},
+}
+
+func TestTemplateFormat(t *testing.T) {
+	for _, tc := range templateFormatTcs {
+		if f := templateFormat(tc.body); f != tc.expected {
+			t.Fatalf("Expected '%v' got '%v'", tc.expected, f)
+		}
+	}
+}
+
+func TestTemplateBodyValue(t *testing.T) {
+	b, err := parseTemplateBody(yamlTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	v, err := b.value()
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	doc := v.(map[string]interface{})
+	if doc["AWSTemplateFormatVersion"] != "2010-09-09" {
+		t.Fatalf("Expected '2010-09-09' got '%v'", doc["AWSTemplateFormatVersion"])
+	}
+
+	properties := doc["Resources"].(map[string]interface{})["Instance"].(map[string]interface{})["Properties"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"ImageId":  map[string]interface{}{"Ref": "Image"},
+		"UserData": map[string]interface{}{"Fn::Base64": map[string]interface{}{"Fn::Sub": "echo ${AWS::Region}"}},
+		"Tags": []interface{}{
+			map[string]interface{}{"Key": "Zone", "Value": map[string]interface{}{"Fn::GetAtt": []interface{}{"Subnet", "AvailabilityZone"}}},
+			map[string]interface{}{"Key": "Name", "Value": map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{map[string]interface{}{"Ref": "AWS::StackName"}, "app"}}}},
+		},
+	}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, properties)
+	}
+}
+
+func TestParseYAMLTemplate(t *testing.T) {
+	tmpl, err := parseTemplate(yamlTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	p := tmpl.Parameters["Password"]
+	if !p.NoEcho || p.MinLength == nil || float64(*p.MinLength) != 8 {
+		t.Fatalf("Expected a NoEcho parameter with MinLength 8 got '%v'", p)
+	}
+
+	if tmpl.Resources["Instance"].Type != "AWS::EC2::Instance" || !reflect.DeepEqual(tmpl.transforms(), []string{"AWS::Serverless-2016-10-31"}) {
+		t.Fatalf("Expected resources and transforms got '%v'", tmpl)
+	}
+}
+
+func TestTemplateBodyEditKeepsFormat(t *testing.T) {
+	json := `{"Parameters": {"Zeta": {"Type": "String", "Default": "a<b"}, "Alpha": {"Type": "Number", "Default": 5}}, "Resources": {}}`
+	b, err := parseTemplateBody(json)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	setString(mappingValue(mappingValue(mappingValue(b.root(), "Parameters"), "Zeta"), "Type"), "CommaDelimitedList")
+
+	out, err := b.String()
+	expected := `{
+  "Parameters": {
+    "Zeta": {
+      "Type": "CommaDelimitedList",
+      "Default": "a<b"
+    },
+    "Alpha": {
+      "Type": "Number",
+      "Default": 5
+    }
+  },
+  "Resources": {}
+}
+`
+	if err != nil || out != expected {
+		t.Fatalf("Expected '%v' got '%v' (%v)", expected, out, err)
+	}
+
+	b, err = parseTemplateBody(yamlTemplate)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+	setString(mappingValue(mappingValue(mappingValue(b.root(), "Parameters"), "Image"), "Type"), "AWS::EC2::Image::Id")
+
+	out, err = b.String()
+	for _, s := range []string{"# The image to run", "Type: AWS::EC2::Image::Id", "ImageId: !Ref Image", "!GetAtt Subnet.AvailabilityZone"} {
+		if err != nil || !strings.Contains(out, s) {
+			t.Fatalf("Expected '%v' got '%v' (%v)", s, out, err)
+		}
+	}
+}
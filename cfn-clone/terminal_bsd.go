@@ -0,0 +1,8 @@
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package main
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TIOCGETA
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// isTerminal reports whether f is an interactive terminal. Prompting relies
+// on stty, so there is no terminal to prompt on elsewhere.
+func isTerminal(f *os.File) bool {
+	return false
+}
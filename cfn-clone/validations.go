@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
@@ -12,13 +13,76 @@ func validateCliExists(cmd string) error {
 	return err
 }
 
-func validateCliParameters(params []string) error {
-	for _, p := range params {
+func validateBackend(backend string) error {
+	if backend != cliBackend && backend != sdkBackend {
+		return errors.New("Backend '" + backend + "' must be one of '" + cliBackend + "' or '" + sdkBackend + "'")
+	}
+	return nil
+}
+
+func validateCapabilities(values []string) error {
+	for _, c := range capabilitiesFromCli(values) {
+		known := false
+		for _, k := range knownCapabilities {
+			known = known || c.Name == k
+		}
+		if !known {
+			return errors.New("Capability '" + c.Name + "' must be one of " + strings.Join(knownCapabilities, ", "))
+		}
+	}
+	return nil
+}
+
+func validateKeyValuePairs(kind string, pairs []string) error {
+	for _, p := range pairs {
 		v := strings.SplitN(p, "=", 2)
 		if len(v) != 2 {
-			return errors.New("Attribute '" + p + "' must be '=' separated key, value")
+			return errors.New(kind + " '" + p + "' must be '=' separated key, value")
+		}
+	}
+	return nil
+}
+
+func validateCliParameters(params []string) error {
+	return validateKeyValuePairs("Attribute", params)
+}
+
+func validateCliTags(tags []string) error {
+	return validateKeyValuePairs("Tag", tags)
+}
+
+// validateOverrideKeys rejects override keys the template does not declare,
+// suggesting the closest declared name for likely typos.
+func validateOverrideKeys(t *cfnTemplate, overrides map[string]string) error {
+	declared := []string{}
+	for k := range t.Parameters {
+		declared = append(declared, k)
+	}
+	sort.Strings(declared)
+
+	keys := []string{}
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, k := range keys {
+		if _, ok := t.Parameters[k]; ok {
+			continue
+		}
+
+		line := "  " + k
+		if c, l := closestChoice(k, declared); c != "" && float32(l)/float32(len(c)) < 0.5 {
+			line += ", did you mean '" + c + "'?"
 		}
+		lines = append(lines, line)
 	}
+
+	if len(lines) > 0 {
+		return errors.New("The template does not declare these parameters (use --allow-unknown-params to send them anyway):\n" + strings.Join(lines, "\n"))
+	}
+
 	return nil
 }
 
@@ -31,19 +95,101 @@ func validateTemplateExists(path string) error {
 	return nil
 }
 
-func validateSourceStackExists(name string) error {
-	args := []string{
-		"cloudformation",
-		"describe-stacks",
-		"--stack-name",
-		name,
+func validateParametersFilesExist(paths []string) error {
+	for _, p := range paths {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			return err
+		}
 	}
+	return nil
+}
 
-	cmd := exec.Command("aws", args...)
+func validateRewriteMapExists(path string) error {
+	if path != "" {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateChangeSetFlags(o *options) error {
+	if o.Approve && !o.ChangeSet && !o.Sync {
+		return errors.New("--approve can only be set with --change-set or --sync")
+	}
+	return nil
+}
+
+func validateExportFlags(o *options) error {
+	if o.NoExportRename && (o.ExportPrefix != "" || o.ExportSuffix != "") {
+		return errors.New("--export-prefix and --export-suffix can not be set with --no-export-rename")
+	}
+	return nil
+}
+
+// validatePhysicalNames checks --physical-names is one of the known modes.
+func validatePhysicalNames(mode string) error {
+	if mode != physicalNamesKeep && mode != physicalNamesSuffix && mode != physicalNamesRemove {
+		return errors.New("Physical names '" + mode + "' must be one of '" + physicalNamesKeep + "', '" + physicalNamesSuffix + "' or '" + physicalNamesRemove + "'")
+	}
+	return nil
+}
+
+// validateSettingsFlags rejects overriding and dropping the same stack
+// setting at once.
+func validateSettingsFlags(o *options) error {
+	conflicts := []struct {
+		override bool
+		drop     bool
+		flags    string
+	}{
+		{len(o.NotificationARNs) > 0, o.NoNotifications, "--notification-arn and --no-notifications"},
+		{o.StackRoleARN != "", o.NoStackRole, "--stack-role-arn and --no-stack-role"},
+		{o.StackPolicyFile != "", o.NoStackPolicy, "--stack-policy-file and --no-stack-policy"},
+		{o.TerminationProtection, o.NoTerminationProtection, "--termination-protection and --no-termination-protection"},
+		{o.RollbackConfigurationFile != "", o.NoRollbackConfiguration, "--rollback-configuration-file and --no-rollback-configuration"},
+		{o.TimeoutInMinutes > 0, o.NoTimeout, "--timeout-in-minutes and --no-timeout"},
+	}
+
+	for _, c := range conflicts {
+		if c.override && c.drop {
+			return errors.New("Only one of " + c.flags + " can be set")
+		}
+	}
+
+	for _, path := range []string{o.StackPolicyFile, o.RollbackConfigurationFile} {
+		if path != "" {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateNoEchoOverridden reports every masked NoEcho parameter at once,
+// described from the given templates.
+func validateNoEchoOverridden(masked []string, templates ...*cfnTemplate) error {
+	if len(masked) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	for _, k := range masked {
+		line := "  " + k
+		if d := parameterDescription(k, templates...); d != "" {
+			line += ": " + d
+		}
+		lines = append(lines, line)
+	}
+
+	return errors.New("NoEcho parameters can not be copied from the source stack, set them with -a or --parameters-file:\n" + strings.Join(lines, "\n"))
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return errors.New("Error verifying source stack. Error: " + string(output))
+func validateSourceStackExists(client CloudFormationClient, name string) error {
+	if _, err := client.DescribeStack(name); err != nil {
+		return errors.New("Error verifying source stack. Error: " + err.Error())
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package main
 
 import (
 	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -22,6 +23,23 @@ func TestValidateCliParameters(t *testing.T) {
 	}
 }
 
+var cliTagsTcs = []struct {
+	tags          []string
+	resultIsError bool
+}{
+	{[]string{"Owner=me", "Empty="}, false},
+	{[]string{"Owner"}, true},
+}
+
+func TestValidateCliTags(t *testing.T) {
+	for _, tc := range cliTagsTcs {
+		err := validateCliTags(tc.tags)
+		if (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v' for '%v'", tc.resultIsError, err, tc.tags)
+		}
+	}
+}
+
 var cliExistsTcs = []struct {
 	cmd           string
 	resultIsError bool
@@ -66,3 +84,115 @@ func TestValidateTemplateExists(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateOverrideKeys(t *testing.T) {
+	tmpl, err := parseTemplate(`{"Parameters": {"InstanceType": {"Type": "String"}, "KeyName": {"Type": "String"}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	if err = validateOverrideKeys(tmpl, map[string]string{"InstanceType": "m5.large"}); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	err = validateOverrideKeys(tmpl, map[string]string{"InstanceTpye": "m5.large", "Completely": "different"})
+	if err == nil {
+		t.Fatalf("Expected error for unknown keys")
+	}
+
+	if !strings.Contains(err.Error(), "InstanceTpye, did you mean 'InstanceType'?") {
+		t.Fatalf("Expected suggestion in '%v'", err)
+	}
+
+	if !strings.Contains(err.Error(), "  Completely\n") && !strings.HasSuffix(err.Error(), "  Completely") {
+		t.Fatalf("Expected unknown key without suggestion in '%v'", err)
+	}
+}
+
+func TestValidateNoEchoOverridden(t *testing.T) {
+	if err := validateNoEchoOverridden(nil); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	tmpl, err := parseTemplate(`{"Parameters": {"Password": {"Type": "String", "NoEcho": true, "Description": "Database password"}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+
+	err = validateNoEchoOverridden([]string{"ApiKey", "Password"}, nil, tmpl)
+	expected := "NoEcho parameters can not be copied from the source stack, set them with -a or --parameters-file:\n  ApiKey\n  Password: Database password"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected '%v' got '%v'", expected, err)
+	}
+}
+
+var capabilitiesTcs = []struct {
+	values        []string
+	resultIsError bool
+}{
+	{nil, false},
+	{[]string{"CAPABILITY_IAM,CAPABILITY_NAMED_IAM"}, false},
+	{[]string{"CAPABILITY_AUTO_EXPAND"}, false},
+	{[]string{"CAPABILITY_ALL"}, true},
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	for _, tc := range capabilitiesTcs {
+		err := validateCapabilities(tc.values)
+		if (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v' for '%v'", tc.resultIsError, err, tc.values)
+		}
+	}
+}
+
+var settingsFlagsTcs = []struct {
+	opts          *options
+	resultIsError bool
+}{
+	{&options{NotificationARNs: []string{"arn:a"}, NoStackRole: true, TimeoutInMinutes: 5}, false},
+	{&options{NotificationARNs: []string{"arn:a"}, NoNotifications: true}, true},
+	{&options{TerminationProtection: true, NoTerminationProtection: true}, true},
+	{&options{TimeoutInMinutes: 5, NoTimeout: true}, true},
+	{&options{StackPolicyFile: "/no/such/policy.json"}, true},
+}
+
+func TestValidateSettingsFlags(t *testing.T) {
+	for _, tc := range settingsFlagsTcs {
+		err := validateSettingsFlags(tc.opts)
+		if (err != nil) != tc.resultIsError {
+			t.Fatalf("Expected '%v' got '%v' for '%+v'", tc.resultIsError, err, tc.opts)
+		}
+	}
+}
+
+func TestValidateChangeSetFlags(t *testing.T) {
+	if err := validateChangeSetFlags(&options{Approve: true}); err == nil {
+		t.Fatalf("Expected an error for --approve without --change-set")
+	}
+
+	if err := validateChangeSetFlags(&options{Approve: true, ChangeSet: true}); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+}
+
+func TestValidateExportFlags(t *testing.T) {
+	if err := validateExportFlags(&options{NoExportRename: true, ExportSuffix: "-b"}); err == nil {
+		t.Fatalf("Expected an error for --export-suffix with --no-export-rename")
+	}
+
+	if err := validateExportFlags(&options{ExportPrefix: "a-", ExportSuffix: "-b"}); err != nil {
+		t.Fatalf("Expected no error got '%v'", err)
+	}
+}
+
+func TestValidatePhysicalNames(t *testing.T) {
+	for _, mode := range []string{physicalNamesKeep, physicalNamesSuffix, physicalNamesRemove} {
+		if err := validatePhysicalNames(mode); err != nil {
+			t.Fatalf("Expected no error for '%s' got '%v'", mode, err)
+		}
+	}
+
+	if err := validatePhysicalNames("rename"); err == nil {
+		t.Fatalf("Expected an error for 'rename'")
+	}
+}